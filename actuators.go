@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// actuator is an output that must be switched off on shutdown.
+type actuator struct {
+	name string
+	off  func() error
+}
+
+// addActuator registers an output to be switched off on shutdown and
+// emergency stop.
+func (g *Gardener) addActuator(name string, off func() error) {
+	g.actuators = append(g.actuators, actuator{name: name, off: off})
+}
+
+// shutdownOrder returns the actuators in the order they are switched
+// off: those named in config.ShutdownOrder first, in that order, then
+// the rest in the order they were added. The pump is added first, so by
+// default flow stops before anything downstream of it.
+func (g *Gardener) shutdownOrder() []actuator {
+	var ordered []actuator
+	for _, name := range config.ShutdownOrder {
+		i := slices.IndexFunc(g.actuators, func(a actuator) bool { return a.name == name })
+		if i < 0 {
+			slog.Warn("unknown actuator in shutdown order", "actuator", name)
+			continue
+		}
+		ordered = append(ordered, g.actuators[i])
+	}
+	for _, a := range g.actuators {
+		if !slices.ContainsFunc(ordered, func(o actuator) bool { return o.name == a.name }) {
+			ordered = append(ordered, a)
+		}
+	}
+	return ordered
+}
+
+// actuatorsOff switches every actuator off in shutdown order, waiting
+// delay between steps.
+func (g *Gardener) actuatorsOff(delay time.Duration) {
+	for i, a := range g.shutdownOrder() {
+		if i > 0 && delay > 0 {
+			time.Sleep(delay)
+		}
+		slog.Info("actuator off", "actuator", a.name)
+		if err := a.off(); err != nil {
+			slog.Error("failed to turn actuator off", "actuator", a.name, "error", err)
+		}
+	}
+}
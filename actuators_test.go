@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// mockRelay records when it is switched off in a log shared with the
+// other relays, so the order of the offs can be checked.
+type mockRelay struct {
+	name string
+	offs *[]string
+	at   *[]time.Time
+	err  error
+}
+
+func (r *mockRelay) Off() error {
+	*r.offs = append(*r.offs, r.name)
+	*r.at = append(*r.at, time.Now())
+	return r.err
+}
+
+func TestActuatorsOffOrder(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		order []string
+		wants []string
+	}{
+		{"added order", nil, []string{"pump", "valve-beds", "valve-lawn", "led"}},
+		{"configured first", []string{"valve-lawn", "pump"}, []string{"valve-lawn", "pump", "valve-beds", "led"}},
+		{"unknown names skipped", []string{"fan", "led"}, []string{"led", "pump", "valve-beds", "valve-lawn"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			saved := config
+			t.Cleanup(func() { config = saved })
+			config.ShutdownOrder = tc.order
+
+			var offs []string
+			var at []time.Time
+			g := &Gardener{}
+			for _, name := range []string{"pump", "valve-beds", "valve-lawn", "led"} {
+				r := &mockRelay{name: name, offs: &offs, at: &at}
+				// A relay that fails must not hold up those after it.
+				if name == "valve-beds" {
+					r.err = errors.New("relay stuck")
+				}
+				g.addActuator(name, r.Off)
+			}
+
+			const delay = 20 * time.Millisecond
+			g.actuatorsOff(delay)
+			if !slices.Equal(offs, tc.wants) {
+				t.Errorf("off order %q, want %q", offs, tc.wants)
+			}
+			for i := 1; i < len(at); i++ {
+				if gap := at[i].Sub(at[i-1]); gap < delay {
+					t.Errorf("%s off %s after %s, want at least %s", offs[i], gap, offs[i-1], delay)
+				}
+			}
+		})
+	}
+}
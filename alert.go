@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+)
+
+// Alert is a station condition that needs a human's attention, such
+// as a pump that did not start when commanded.
+type Alert struct {
+	Name    string    `json:"alert"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// Alert logs the alert and publishes it on e/alert.
+func (g *Gardener) Alert(name, message string) {
+	a := Alert{Name: name, Message: message, Time: now()}
+	slog.Error("alert", "alert", a.Name, "message", a.Message)
+	if g.summary != nil {
+		g.summary.Alert(name)
+	}
+
+	jbuf, err := json.Marshal(a)
+	if err != nil {
+		slog.Error("alert marshal failed", "alert", name, "error", err)
+		return
+	}
+	g.publish("e/alert", jbuf)
+}
@@ -1,13 +1,78 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
 )
 
 //go:embed app
 var tmpldir embed.FS
 
 func (g *Gardener) InitApp() {
-	s := g.Server
+	s := g.GetServer()
 	s.EmbedTempl("/", tmpldir, g)
+	s.Register("/api/diagnostics", g.diag)
+	s.Register("/metrics", g.metrics)
+	s.Register("/api/sensors", http.HandlerFunc(g.serveSensors))
+	s.Register("/api/summary", http.HandlerFunc(g.serveSummary))
+	s.Register("/livez", http.HandlerFunc(g.serveLive))
+	s.Register("/readyz", http.HandlerFunc(g.serveReady))
+	s.Register("/api/simulate", http.HandlerFunc(g.serveSimulate))
+	s.Register("/api/features", http.HandlerFunc(g.serveFeatures))
+	s.Register("/api/pump/test", http.HandlerFunc(g.servePumpTest))
+	s.Register("/api/profile", http.HandlerFunc(g.serveProfile))
+	if g.emu != nil {
+		s.Register("/api/emulator", g.emu)
+	}
+	// Register only fills the mux; without this the server would
+	// serve http.DefaultServeMux and every route would be a 404.
+	s.Server.Handler = s.ServeMux
+}
+
+// startServer serves HTTP in the background. Binding is retried with
+// backoff for config.HTTPBindRetry, since a fast restart can find the
+// port still held by the previous process. The server is optional, so
+// if it never binds the station carries on without it.
+func (g *Gardener) startServer() {
+	s := g.GetServer()
+	g.goSafe("http-server", func() {
+		ln, err := listenRetry(g.ctx, s.Addr, config.HTTPBindRetry)
+		if err != nil {
+			slog.Error("http server failed to bind, continuing without it", "addr", s.Addr, "error", err)
+			return
+		}
+		err = s.Serve(ln)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("http server failed", "error", err)
+		}
+	})
+}
+
+// listenRetry listens on addr, retrying with doubling backoff until
+// window has passed or ctx is done.
+func listenRetry(ctx context.Context, addr string, window time.Duration) (net.Listener, error) {
+	if addr == "" {
+		addr = ":http"
+	}
+	deadline := time.Now().Add(window)
+	backoff := 250 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		ln, err := net.Listen("tcp", addr)
+		if err == nil {
+			return ln, nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return nil, err
+		}
+		slog.Warn("http server bind failed, retrying", "addr", addr, "attempt", attempt, "retry_in", backoff, "error", err)
+		if !sleepOrStop(backoff, ctx.Done()) {
+			return nil, ctx.Err()
+		}
+		backoff = min(backoff*2, 5*time.Second)
+	}
 }
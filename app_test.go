@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rustyeddy/otto/server"
+)
+
+func TestInitAppServesRoutes(t *testing.T) {
+	g := &Gardener{Server: server.NewServer()}
+	g.InitApp()
+
+	for _, path := range []string{"/livez", "/api/sensors"} {
+		rec := httptest.NewRecorder()
+		g.Server.Server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s: status %d, want %d: %s", path, rec.Code, http.StatusOK, strings.TrimSpace(rec.Body.String()))
+		}
+	}
+}
+
+// TestListenRetryStops checks a bind that keeps failing gives up as
+// soon as the station stops rather than at the end of the window.
+func TestListenRetryStops(t *testing.T) {
+	held, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	ln, err := listenRetry(ctx, held.Addr().String(), time.Hour)
+	if err == nil {
+		ln.Close()
+		t.Fatal("listenRetry bound a port already in use")
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("listenRetry took %s to stop", d)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rustyeddy/otto/messenger"
+	"gopkg.in/yaml.v3"
+)
+
+// BridgeRule republishes another station's topic under this station's
+// taxonomy. The payload is passed through as is, or, when any of
+// Field, Scale or Offset is given, read as a number, from the JSON
+// field Field (dotted for nested objects) or else the whole payload,
+// and republished as value*Scale+Offset.
+type BridgeRule struct {
+	Source string   `yaml:"source"`
+	Target string   `yaml:"target"`
+	Field  string   `yaml:"field"`
+	Scale  *float64 `yaml:"scale"`
+	Offset float64  `yaml:"offset"`
+}
+
+func (b *BridgeRule) validate() error {
+	if b.Source == "" || b.Target == "" {
+		return errors.New("source and target are required")
+	}
+	if b.Source == b.Target {
+		return fmt.Errorf("source and target are both %q, which would loop", b.Source)
+	}
+	return nil
+}
+
+// transform turns a source payload into the target payload.
+func (b *BridgeRule) transform(data []byte) ([]byte, error) {
+	if b.Field == "" && b.Scale == nil && b.Offset == 0 {
+		return data, nil
+	}
+	var v float64
+	var err error
+	if b.Field == "" {
+		v, err = strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+	} else {
+		v, err = jsonField(data, b.Field)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if b.Scale != nil {
+		v *= *b.Scale
+	}
+	v += b.Offset
+	if !finite(v) {
+		return nil, fmt.Errorf("value %v is not finite", v)
+	}
+	return []byte(strconv.FormatFloat(v, 'f', -1, 64)), nil
+}
+
+// jsonField returns the number at the dotted path in a JSON object.
+func jsonField(data []byte, path string) (float64, error) {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return 0, err
+	}
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := doc.(map[string]any)
+		if !ok {
+			return 0, fmt.Errorf("no field %q", path)
+		}
+		if doc, ok = obj[key]; !ok {
+			return 0, fmt.Errorf("no field %q", path)
+		}
+	}
+	v, ok := doc.(float64)
+	if !ok {
+		return 0, fmt.Errorf("field %q is not a number", path)
+	}
+	return v, nil
+}
+
+func loadBridge(path string) ([]*BridgeRule, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []*BridgeRule
+	if err := yaml.Unmarshal(buf, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, r := range rules {
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("%s: bridge %d: %w", path, i+1, err)
+		}
+	}
+	return rules, nil
+}
+
+// initBridge loads config.BridgeFile, panicking on a malformed one.
+func (g *Gardener) initBridge() {
+	if config.BridgeFile == "" {
+		return
+	}
+	rules, err := loadBridge(config.BridgeFile)
+	if err != nil {
+		panic(err)
+	}
+	g.bridge = rules
+}
+
+// startBridge subscribes to every bridged source topic.
+func (g *Gardener) startBridge() {
+	for _, b := range g.bridge {
+		slog.Info("bridging topic", "source", b.Source, "target", b.Target)
+		g.subscribe(b.Source, func(msg *messenger.Msg) error {
+			data, err := b.transform(msg.Data)
+			if err != nil {
+				return fmt.Errorf("bridge %s: bad payload %q: %w", b.Source, msg.Data, err)
+			}
+			g.publish(b.Target, data)
+			return nil
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Calibration is a linear correction, value = raw*Scale + Offset,
+// applied after a device's own conversion.
+type Calibration struct {
+	Scale  float64 `json:"scale"`
+	Offset float64 `json:"offset"`
+}
+
+func (c Calibration) apply(v float64) float64 {
+	return v*c.Scale + c.Offset
+}
+
+// calibrations is a comma separated list of name=scale:offset flag
+// values. The name is a single-valued device, e.g. "soil" or
+// "ds18b20-28-0000", or an env sensor field, e.g. "env.temperature",
+// so "env.temperature=1:1.2" corrects a sensor reading 1.2 degrees low.
+type calibrations map[string]Calibration
+
+func (m *calibrations) String() string {
+	if m == nil {
+		return ""
+	}
+	var parts []string
+	for name, c := range *m {
+		parts = append(parts, fmt.Sprintf("%s=%g:%g", name, c.Scale, c.Offset))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (m *calibrations) Set(v string) error {
+	if *m == nil {
+		*m = make(calibrations)
+	}
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, cal, ok := strings.Cut(part, "=")
+		scale, offset, ok2 := strings.Cut(cal, ":")
+		if !ok || !ok2 || name == "" {
+			return fmt.Errorf("%q: expected name=scale:offset", part)
+		}
+		var c Calibration
+		var err error
+		if c.Scale, err = strconv.ParseFloat(scale, 64); err != nil || !finite(c.Scale) || c.Scale == 0 {
+			return fmt.Errorf("%q: scale must be a non-zero number", part)
+		}
+		if c.Offset, err = strconv.ParseFloat(offset, 64); err != nil || !finite(c.Offset) {
+			return fmt.Errorf("%q: offset must be a number", part)
+		}
+		(*m)[name] = c
+	}
+	return nil
+}
+
+// calibrate corrects v, read by the named device or device.field, by
+// its calibration, if it has one.
+func calibrate(name string, v float64) float64 {
+	if c, ok := config.Calibrate[name]; ok {
+		return c.apply(v)
+	}
+	return v
+}
+
+// calibrateFields corrects each field of a reading from device in
+// place.
+func calibrateFields(device string, fields map[string]float64) {
+	for field, v := range fields {
+		fields[field] = calibrate(device+"."+field, v)
+	}
+}
+
+// validateCalibrations checks every calibration names a device, or a
+// field of one, that this station has.
+func validateCalibrations() error {
+	specs, _ := envSensors()
+	known := map[string]bool{"soil": true}
+	for _, s := range specs {
+		for _, f := range []string{"temperature", "humidity", "pressure"} {
+			known[s.name+"."+f] = true
+		}
+	}
+	var bad []string
+	for name := range config.Calibrate {
+		if !known[name] && !strings.HasPrefix(name, "ds18b20-") {
+			bad = append(bad, name)
+		}
+	}
+	if len(bad) > 0 {
+		sort.Strings(bad)
+		return fmt.Errorf("no such device or field: %s", strings.Join(bad, ", "))
+	}
+	return nil
+}
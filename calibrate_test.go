@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestCalibrationApply(t *testing.T) {
+	tests := []struct {
+		c    Calibration
+		raw  float64
+		want float64
+	}{
+		{Calibration{Scale: 1}, 21.5, 21.5},
+		{Calibration{Scale: 1, Offset: 1.2}, 20, 21.2},
+		{Calibration{Scale: 2, Offset: -10}, 30, 50},
+		{Calibration{Scale: 0.5}, 0, 0},
+		{Calibration{Scale: -1, Offset: 100}, 30, 70},
+	}
+	for _, tt := range tests {
+		if got := tt.c.apply(tt.raw); got != tt.want {
+			t.Errorf("%+v.apply(%g) = %g, want %g", tt.c, tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestCalibrate(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config.Calibrate = nil
+	if err := config.Calibrate.Set("soil=2:-10, env.temperature=1:1.5"); err != nil {
+		t.Fatal(err)
+	}
+	if got := calibrate("soil", 30); got != 50 {
+		t.Errorf("soil = %g, want 50", got)
+	}
+	if got := calibrate("ds18b20-28-0000", 30); got != 30 {
+		t.Errorf("uncalibrated device = %g, want 30 unchanged", got)
+	}
+	fields := map[string]float64{"temperature": 20, "humidity": 40}
+	calibrateFields("env", fields)
+	if fields["temperature"] != 21.5 || fields["humidity"] != 40 {
+		t.Errorf("fields = %v, want temperature 21.5, humidity 40", fields)
+	}
+}
+
+func TestCalibrationsSet(t *testing.T) {
+	for _, v := range []string{"soil", "soil=2", "=1:0", "soil=0:1", "soil=x:1", "soil=1:y", "soil=NaN:0", "soil=1:Inf"} {
+		var m calibrations
+		if err := m.Set(v); err == nil {
+			t.Errorf("Set(%q) = nil, want an error", v)
+		}
+	}
+	var m calibrations
+	if err := m.Set("soil=1.1:-2,env.humidity=1:3"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.String(), "env.humidity=1:3,soil=1.1:-2"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFetchTimeout bounds fetching -config-url at boot.
+const configFetchTimeout = 10 * time.Second
+
+// errMalformedConfig marks a remote config that was fetched but is not
+// valid, which the station refuses to boot on without a cached copy.
+var errMalformedConfig = errors.New("malformed config")
+
+// parseConfigFile parses a config file, a YAML mapping of option names
+// to values, e.g. "mqtt-broker: otto" or "env-sensor: [indoor=0x76]".
+func parseConfigFile(buf []byte) (map[string]string, error) {
+	var raw map[string]any
+	if err := yaml.Unmarshal(buf, &raw); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch v := v.(type) {
+		case []any:
+			parts := make([]string, len(v))
+			for i, p := range v {
+				parts[i] = fmt.Sprint(p)
+			}
+			values[k] = strings.Join(parts, ",")
+		case map[string]any:
+			return nil, fmt.Errorf("%s: nested values are not options", k)
+		default:
+			values[k] = fmt.Sprint(v)
+		}
+	}
+	return values, nil
+}
+
+// applyConfigFile sets the options in buf that were not given on the
+// command line or in the environment. Every option is parsed into a
+// scratch config first, so nothing is changed if any of them is unknown
+// or invalid.
+func applyConfigFile(fs *flag.FlagSet, buf []byte) error {
+	values, err := parseConfigFile(buf)
+	if err != nil {
+		return err
+	}
+	names := slices.Sorted(maps.Keys(values))
+	var scratch Config
+	check := flag.NewFlagSet("config", flag.ContinueOnError)
+	defineFlags(check, &scratch)
+	for _, name := range names {
+		if fs.Lookup(name) == nil || check.Lookup(name) == nil {
+			return fmt.Errorf("unknown option %q", name)
+		}
+		if err := check.Lookup(name).Value.Set(values[name]); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, name := range names {
+		if _, env := os.LookupEnv(envName(name)); set[name] || env {
+			continue
+		}
+		if err := fs.Lookup(name).Value.Set(values[name]); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// configCachePath is where the last good -config-url in c is kept.
+func configCachePath(c *Config) string {
+	if c.ConfigCache != "" || c.DataDir == "" {
+		return c.ConfigCache
+	}
+	return filepath.Join(c.DataDir, "config-cache.yaml")
+}
+
+func fetchConfig(url string) ([]byte, error) {
+	client := http.Client{Timeout: configFetchTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// LoadConfig returns a fresh config of the defaults, overridden by the
+// config file, then the environment, then the options given on the
+// command line. The config file is -config-url, falling back to its
+// cached copy, else path or, if path is empty, -config. It leaves the
+// global config alone, and returns an error if the file is malformed
+// or names an unknown option.
+func LoadConfig(path string) (Config, error) {
+	return loadConfigFrom(flag.CommandLine, path)
+}
+
+// loadConfigFrom is LoadConfig with the command line options taken from
+// the ones set in cmd.
+func loadConfigFrom(cmd *flag.FlagSet, path string) (Config, error) {
+	var c Config
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	defineFlags(fs, &c)
+	var err error
+	cmd.Visit(func(f *flag.Flag) {
+		if err == nil && fs.Lookup(f.Name) != nil {
+			err = fs.Set(f.Name, f.Value.String())
+		}
+	})
+	if err != nil {
+		return Config{}, err
+	}
+	if err := applyEnv(fs); err != nil {
+		return Config{}, err
+	}
+	if path == "" {
+		path = c.ConfigFile
+	}
+	if err := applyConfigLayer(fs, &c, path); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+// applyConfigLayer applies the config file layer to fs, which is bound
+// to c: -config-url, cached after every good fetch, falling back to
+// the cached copy when the fetch fails or the fetched config is
+// malformed, then the local file at path when there is no remote
+// config at all.
+func applyConfigLayer(fs *flag.FlagSet, c *Config, path string) error {
+	if c.ConfigURL != "" {
+		url := c.ConfigURL
+		buf, err := fetchConfig(url)
+		if err == nil {
+			if err = applyConfigFile(fs, buf); err == nil {
+				slog.Info("config fetched", "url", url)
+				if cache := configCachePath(c); cache != "" {
+					if err := writeAtomic(cache, buf); err != nil {
+						slog.Warn("config cache write failed", "path", cache, "error", err)
+					}
+				}
+				return nil
+			}
+			err = fmt.Errorf("%w from %s: %w", errMalformedConfig, url, err)
+		}
+		slog.Warn("remote config unusable, trying cached copy", "url", url, "error", err)
+
+		cache := configCachePath(c)
+		if cache != "" {
+			buf, cerr := os.ReadFile(cache)
+			if cerr == nil {
+				if cerr = applyConfigFile(fs, buf); cerr != nil {
+					return fmt.Errorf("cached config %s: %w", cache, cerr)
+				}
+				slog.Info("config loaded from cache", "path", cache)
+				return nil
+			}
+			if !errors.Is(cerr, os.ErrNotExist) {
+				return cerr
+			}
+		}
+		if errors.Is(err, errMalformedConfig) {
+			return err
+		}
+	}
+
+	if path == "" {
+		return nil
+	}
+	return loadConfigFile(fs, path)
+}
+
+func loadConfigFile(fs *flag.FlagSet, path string) error {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := applyConfigFile(fs, buf); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// writeAtomic writes data to path via a temporary file, so a power cut
+// mid-write cannot leave a torn file behind.
+func writeAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testFlags returns a config of the defaults and the flag set bound to
+// it.
+func testFlags() (*Config, *flag.FlagSet) {
+	c := new(Config)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	defineFlags(fs, c)
+	return c, fs
+}
+
+func TestApplyConfigFileLeavesConfigOnError(t *testing.T) {
+	defaults, _ := testFlags()
+	for _, tc := range []struct {
+		name string
+		file string
+	}{
+		{"not yaml", "mqtt-broker: [otto\n"},
+		{"nested value", "mqtt-broker:\n  host: otto\n"},
+		{"unknown option", "pin: [pump=9]\nmqtt-brokr: otto\n"},
+		{"bad value", "pin: [pump=9]\ncalibrate: [soil=2:1]\nprecision: [soil=1]\nlow-threshold: wet\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, fs := testFlags()
+			if err := applyConfigFile(fs, []byte(tc.file)); err == nil {
+				t.Fatalf("applyConfigFile(%q) succeeded, want an error", tc.file)
+			}
+			if !reflect.DeepEqual(c, defaults) {
+				t.Fatalf("applyConfigFile(%q) changed the config:\n%+v\nwant\n%+v", tc.file, c, defaults)
+			}
+		})
+	}
+}
+
+func TestApplyConfigFile(t *testing.T) {
+	c, fs := testFlags()
+	if err := fs.Parse([]string{"-station-name=cli"}); err != nil {
+		t.Fatal(err)
+	}
+	file := "station-name: file\nmqtt-broker: broker.local\npin: [pump=23]\n"
+	if err := applyConfigFile(fs, []byte(file)); err != nil {
+		t.Fatal(err)
+	}
+	if c.StationName != "cli" {
+		t.Errorf("station name %q, want the command line's %q", c.StationName, "cli")
+	}
+	if c.Broker != "broker.local" {
+		t.Errorf("broker %q, want the file's %q", c.Broker, "broker.local")
+	}
+	if c.Pins.pin("pump") != 23 || c.Pins.pin("soil") != defaultPins()["soil"] {
+		t.Errorf("pins %s, want pump=23 over the defaults", c.Pins.String())
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gardener.yaml")
+	file := "mqtt-broker: file\nmqtt-username: file\nstation-name: file\n"
+	if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(envName("mqtt-broker"), "env")
+	t.Setenv(envName("mqtt-username"), "env")
+	_, cmd := testFlags()
+	if err := cmd.Parse([]string{"-mqtt-broker=cli"}); err != nil {
+		t.Fatal(err)
+	}
+	// Printed, as a copy would share the maps a bad apply writes to.
+	global := fmt.Sprintf("%+v", config)
+
+	c, err := loadConfigFrom(cmd, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults, _ := testFlags()
+	for _, tc := range []struct {
+		option    string
+		got, want any
+	}{
+		{"mqtt-broker", c.Broker, "cli"},
+		{"mqtt-username", c.Username, "env"},
+		{"station-name", c.StationName, "file"},
+		{"low-threshold", c.LowThreshold, defaults.LowThreshold},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("-%s: %v, want %v", tc.option, tc.got, tc.want)
+		}
+	}
+	if fmt.Sprintf("%+v", config) != global {
+		t.Error("LoadConfig changed the global config")
+	}
+}
+
+func TestLoadConfigMalformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gardener.yaml")
+	if err := os.WriteFile(path, []byte("pin: [pump=9]\nlow-threshold: wet\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	global := fmt.Sprintf("%+v", config)
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("LoadConfig of a malformed file succeeded, want an error")
+	}
+	if fmt.Sprintf("%+v", config) != global {
+		t.Error("LoadConfig changed the global config")
+	}
+}
+
+// TestLoadConfigURLCache loads -config-url, then loads it again with
+// the server gone and checks the cached copy is used.
+func TestLoadConfigURLCache(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "mqtt-broker: fleet")
+	}))
+	dir := t.TempDir()
+	_, cmd := testFlags()
+	if err := cmd.Parse([]string{"-config-url=" + srv.URL, "-data-dir=" + dir}); err != nil {
+		t.Fatal(err)
+	}
+	for _, state := range []string{"served", "cached"} {
+		c, err := loadConfigFrom(cmd, "")
+		if err != nil {
+			t.Fatalf("%s: %v", state, err)
+		}
+		if c.Broker != "fleet" {
+			t.Errorf("%s: broker %q, want %q", state, c.Broker, "fleet")
+		}
+		srv.Close()
+	}
+}
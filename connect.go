@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	gomqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/rustyeddy/otto/messenger"
+)
+
+// errBrokerAuth marks a connection the broker refused for its
+// credentials, which no amount of retrying will fix.
+var errBrokerAuth = errors.New("broker rejected credentials")
+
+// authRefusals are the CONNACK reasons, as the MQTT client words them,
+// that mean the credentials are wrong rather than the network.
+var authRefusals = []string{
+	"bad user name or password",
+	"not authorized",
+	"not authorised",
+}
+
+// isAuthError reports whether a connect error is the broker refusing
+// the credentials.
+func isAuthError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, r := range authRefusals {
+		if strings.Contains(msg, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// mqttQuiesce is how long disconnecting from the broker waits for
+// the messages in flight.
+const mqttQuiesce = time.Second
+
+// brokerURL returns the broker address as the MQTT client wants it: a
+// bare host, such as "otto", is on the standard port over TCP.
+func brokerURL(broker string) string {
+	if strings.Contains(broker, "://") {
+		return broker
+	}
+	if _, _, err := net.SplitHostPort(broker); err != nil {
+		broker = net.JoinHostPort(broker, "1883")
+	}
+	return "tcp://" + broker
+}
+
+// mqttOptions returns the MQTT client options for the configured
+// broker, credentials, keepalive and timeouts. A zero duration keeps
+// the client's default.
+func mqttOptions() *gomqtt.ClientOptions {
+	opts := gomqtt.NewClientOptions()
+	opts.AddBroker(brokerURL(config.Broker))
+	opts.SetClientID(config.StationName)
+	opts.SetCleanSession(true)
+	opts.SetUsername(config.Username)
+	opts.SetPassword(config.Password)
+	if config.MQTTKeepAlive > 0 {
+		opts.SetKeepAlive(config.MQTTKeepAlive)
+	}
+	if config.MQTTConnectTimeout > 0 {
+		opts.SetConnectTimeout(config.MQTTConnectTimeout)
+	}
+	if config.MQTTPingTimeout > 0 {
+		opts.SetPingTimeout(config.MQTTPingTimeout)
+	}
+	return opts
+}
+
+// initMQTT builds the station's MQTT client from mqttOptions. The
+// station connects, publishes and subscribes through this client, so
+// the options above are the ones in force. Each connect, the first and
+// every automatic reconnect, makes the subscriptions again.
+func (g *Gardener) initMQTT() {
+	opts := mqttOptions()
+	opts.SetOnConnectHandler(g.resubscribe)
+	g.mqttClient = gomqtt.NewClient(opts)
+	slog.Info("mqtt client", "broker", brokerURL(config.Broker), "client_id", config.StationName,
+		"keepalive", config.MQTTKeepAlive, "connect_timeout", config.MQTTConnectTimeout, "ping_timeout", config.MQTTPingTimeout)
+}
+
+// disconnectBroker disconnects from the broker, giving the messages in
+// flight mqttQuiesce to go out.
+func (g *Gardener) disconnectBroker() error {
+	g.health.connected.Store(false)
+	if g.mqttClient != nil {
+		g.mqttClient.Disconnect(uint(mqttQuiesce / time.Millisecond))
+	}
+	return nil
+}
+
+// maxConnectBackoff caps the doubling wait between connect attempts.
+const maxConnectBackoff = 30 * time.Second
+
+// connectBroker connects to the broker, retrying network failures with
+// doubling backoff for config.ConnectRetry or until the station stops.
+// A credential failure is not retried, so a wrong password does not
+// hammer the broker.
+func (g *Gardener) connectBroker() error {
+	deadline := time.Now().Add(config.ConnectRetry)
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		err := g.tryConnect()
+		if err == nil || errors.Is(err, errBrokerAuth) {
+			return err
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return err
+		}
+		slog.Warn("broker unreachable, retrying", "broker", config.Broker, "attempt", attempt, "retry_in", backoff, "error", err)
+		if !sleepOrStop(backoff, g.ctx.Done()) {
+			return err
+		}
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+}
+
+// tryConnect makes one attempt to connect to the broker.
+func (g *Gardener) tryConnect() error {
+	tok := g.mqttClient.Connect()
+	tok.Wait()
+	err := tok.Error()
+	if err != nil && isAuthError(err) {
+		slog.Error("broker rejected credentials, not retrying; check -mqtt-username and -mqtt-password",
+			"broker", config.Broker, "username", config.Username, "error", err)
+		return errors.Join(errBrokerAuth, err)
+	}
+	return err
+}
+
+// connectInBackground keeps trying to connect, for a station that
+// started without the broker, until it connects or the station stops.
+// Once connected the client reconnects by itself.
+func (g *Gardener) connectInBackground() {
+	g.goSafe("broker-connect", func() {
+		backoff := time.Second
+		for sleepOrStop(backoff, g.ctx.Done()) {
+			err := g.tryConnect()
+			if err == nil && g.ctx.Err() != nil {
+				// Stop came during the attempt.
+				g.disconnectBroker()
+				return
+			}
+			if err == nil {
+				slog.Info("broker connected", "broker", config.Broker)
+				g.brokerConnected()
+				return
+			}
+			if errors.Is(err, errBrokerAuth) {
+				return
+			}
+			backoff = min(backoff*2, maxConnectBackoff)
+			slog.Warn("broker unreachable, retrying in the background", "broker", config.Broker, "retry_in", backoff, "error", err)
+		}
+	})
+}
+
+// brokerConnected does what waits for the first connection. The
+// client makes the subscriptions on connect too, but in the background;
+// making them here first means none is missed once it returns.
+func (g *Gardener) brokerConnected() {
+	g.resubscribe(g.mqttClient)
+	g.health.connected.Store(true)
+	g.publishGapMarkers()
+	g.publishProfile()
+}
+
+// subscriptions are the station's topics and their handlers, kept to
+// subscribe again whenever the client connects.
+type subscriptions struct {
+	mu       sync.Mutex
+	handlers map[string][]messenger.MsgHandler
+}
+
+// mqttSubscribe adds h to the handlers of topic, subscribing on the
+// broker at once if the client is connected and otherwise when it
+// connects.
+func (g *Gardener) mqttSubscribe(topic string, h messenger.MsgHandler) {
+	s := &g.subs
+	s.mu.Lock()
+	if s.handlers == nil {
+		s.handlers = make(map[string][]messenger.MsgHandler)
+	}
+	s.handlers[topic] = append(s.handlers[topic], h)
+	first := len(s.handlers[topic]) == 1
+	s.mu.Unlock()
+	if first && g.mqttClient != nil && g.mqttClient.IsConnectionOpen() {
+		g.brokerSubscribe(topic)
+	}
+}
+
+// resubscribe subscribes every topic on the broker as the client
+// connects, since a clean session starts with none.
+func (g *Gardener) resubscribe(gomqtt.Client) {
+	g.subs.mu.Lock()
+	topics := slices.Sorted(maps.Keys(g.subs.handlers))
+	g.subs.mu.Unlock()
+	for _, topic := range topics {
+		g.brokerSubscribe(topic)
+	}
+}
+
+// brokerSubscribe subscribes topic on the broker, handing each message
+// to the topic's handlers.
+func (g *Gardener) brokerSubscribe(topic string) {
+	tok := g.mqttClient.Subscribe(topic, 0, func(_ gomqtt.Client, m gomqtt.Message) {
+		msg := &messenger.Msg{Topic: m.Topic(), Data: m.Payload()}
+		g.subs.mu.Lock()
+		handlers := g.subs.handlers[topic]
+		g.subs.mu.Unlock()
+		for _, h := range handlers {
+			if err := h(msg); err != nil {
+				slog.Warn("message handler failed", "topic", msg.Topic, "error", err)
+			}
+		}
+	})
+	if tok.Wait() && tok.Error() != nil {
+		slog.Error("subscribe failed", "topic", topic, "error", tok.Error())
+	}
+}
+
+// mqttPublish publishes data on topic. Without a client, as in a
+// station not yet initialised, the message is dropped.
+func (g *Gardener) mqttPublish(topic string, data []byte) {
+	if g.mqttClient == nil {
+		return
+	}
+	g.mqttClient.Publish(topic, 0, false, data)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"math"
+	"sync"
+)
+
+// deadband decides whether a reading has changed enough to act on: a
+// value only counts as changed once it moves more than width away from
+// the last value that did. It keeps automation near a threshold from
+// flapping on fractions of a percent, without smoothing what is shown.
+type deadband struct {
+	width float64
+
+	mu   sync.Mutex
+	last float64
+	set  bool
+}
+
+// filter returns the value to act on for v, the last value acted on
+// while v stays within the band around it, and whether v moved outside
+// it and so became that value. With no width every value counts as
+// changed.
+func (d *deadband) filter(v float64) (float64, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.set && math.Abs(v-d.last) <= d.width {
+		return d.last, false
+	}
+	d.last, d.set = v, true
+	return v, true
+}
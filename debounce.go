@@ -0,0 +1,94 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/rustyeddy/devices"
+)
+
+// eventSource is a device that reports edges through an event handler,
+// such as a button, float switch or rain sensor.
+type eventSource interface {
+	RegisterEventHandler(func(*devices.DeviceEvent))
+}
+
+// debounce returns a handler that calls h with the last event once no
+// further events have arrived for window, coalescing contact bounce
+// into a single event.
+func debounce(window time.Duration, h func(*devices.DeviceEvent)) func(*devices.DeviceEvent) {
+	if window <= 0 {
+		return h
+	}
+
+	var mu sync.Mutex
+	var timer *time.Timer
+	var last *devices.DeviceEvent
+	return func(evt *devices.DeviceEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		last = evt
+		if timer != nil {
+			timer.Stop()
+		}
+		timer = time.AfterFunc(window, func() {
+			mu.Lock()
+			e := last
+			mu.Unlock()
+			h(e)
+		})
+	}
+}
+
+// coalesce returns a function that holds back each value for window
+// from the first and then calls apply with only the last, so a button
+// on quickly followed by an off collapses to just the off. Unlike
+// debounce the window is not extended by further values, so a stream
+// of presses cannot hold the result off indefinitely.
+func coalesce[T comparable](window time.Duration, apply func(T)) func(T) {
+	if window <= 0 {
+		return apply
+	}
+
+	var mu sync.Mutex
+	var pending bool
+	var first, last T
+	return func(v T) {
+		mu.Lock()
+		defer mu.Unlock()
+		last = v
+		if pending {
+			return
+		}
+		pending, first = true, v
+		time.AfterFunc(window, func() {
+			mu.Lock()
+			f, v := first, last
+			pending = false
+			mu.Unlock()
+			if v != f {
+				slog.Info("coalesced inputs", "first", f, "result", v, "window", window)
+			}
+			apply(v)
+		})
+	}
+}
+
+// debounceWindow returns the debounce window for the named device.
+func debounceWindow(name string) time.Duration {
+	if d, ok := config.DebounceDevices[name]; ok {
+		return d
+	}
+	return config.Debounce
+}
+
+// onEvent registers h for src's events, debounced with the device's
+// window and with panics recovered. Pulse and quadrature inputs, like
+// flow meters and encoders, must register directly instead.
+func (g *Gardener) onEvent(name string, src eventSource, h func(*devices.DeviceEvent)) {
+	src.RegisterEventHandler(debounce(debounceWindow(name), func(evt *devices.DeviceEvent) {
+		defer g.recoverPanic(name)
+		h(evt)
+	}))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rustyeddy/devices"
+)
+
+// edges returns n events alternating rising and falling, like a
+// bouncing contact, ending on a rising edge when n is odd.
+func edges(n int) []*devices.DeviceEvent {
+	var evts []*devices.DeviceEvent
+	for i := 0; i < n; i++ {
+		typ := devices.DeviceEventRisingEdge
+		if i%2 == 1 {
+			typ = devices.DeviceEventFallingEdge
+		}
+		evts = append(evts, &devices.DeviceEvent{Type: typ})
+	}
+	return evts
+}
+
+func TestDebounceBurst(t *testing.T) {
+	var mu sync.Mutex
+	var got []*devices.DeviceEvent
+	h := debounce(30*time.Millisecond, func(evt *devices.DeviceEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, evt)
+	})
+
+	var want []*devices.DeviceEvent
+	for range 2 {
+		burst := edges(9)
+		for _, evt := range burst {
+			h(evt)
+			time.Sleep(time.Millisecond)
+		}
+		want = append(want, burst[len(burst)-1])
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != len(want) {
+		t.Fatalf("handler called %d times, want once per burst (%d)", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("burst %d delivered %+v, want the last event %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDebounceNoWindow(t *testing.T) {
+	var n int
+	h := debounce(0, func(*devices.DeviceEvent) { n++ })
+	for _, evt := range edges(5) {
+		h(evt)
+	}
+	if n != 5 {
+		t.Errorf("handler called %d times, want every event (5) with no window", n)
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	var mu sync.Mutex
+	var got []bool
+	apply := coalesce(50*time.Millisecond, func(on bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, on)
+	})
+
+	// A stream of presses longer than the window still applies, once
+	// per window, with the last value each time.
+	for i := 0; i < 80; i++ {
+		apply(i%2 == 0)
+		time.Sleep(time.Millisecond)
+	}
+	apply(false)
+	time.Sleep(150 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) < 2 {
+		t.Fatalf("applied %v, want the stream applied at least once per window", got)
+	}
+	if got[len(got)-1] {
+		t.Errorf("applied %v, want it to end on the last value, false", got)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+// deepWaterCheck is how often the deep-watering schedule is checked.
+const deepWaterCheck = time.Minute
+
+// Watering types, logged with every watering the controller starts.
+const (
+	wateringTopUp = "top-up"
+	wateringDeep  = "deep"
+)
+
+// nextDeepWater returns when the deep soak after one at last is due:
+// config.DeepWaterAt on the first day at least config.DeepWaterEvery
+// after it. With no deep soak yet, it is the first DeepWaterAt after
+// start.
+func nextDeepWater(last, start time.Time) time.Time {
+	at, _ := parseTOD(config.DeepWaterAt)
+	from := start
+	if !last.IsZero() {
+		from = last.Add(config.DeepWaterEvery)
+	}
+	due := onDay(from, at)
+	if due.Before(from) {
+		due = onDay(from.AddDate(0, 0, 1), at)
+	}
+	if last.IsZero() || !due.Before(start) {
+		return due
+	}
+	// A deep soak missed while the station was down runs at the next
+	// DeepWaterAt rather than as soon as it comes up.
+	return nextDeepWater(time.Time{}, start)
+}
+
+// DeepWater runs a deep soak: the pump on for config.DeepWaterDuration,
+// then top-ups suppressed for config.DeepWaterCooldown. It is skipped
+// while the buttons have the pump, watering is held off or a profile
+// other than normal is active.
+func (w *WaterController) DeepWater(t time.Time) {
+	w.mu.Lock()
+	if w.mode != modeAuto || w.suppressed(t) || w.g.emergencyStopped() || w.g.offlinePolicy() == offlineConservative ||
+		(w.profile != nil && w.profile.Name != profileNormal) {
+		w.mu.Unlock()
+		slog.Info("deep watering skipped", "mode", w.Mode(), "time", t)
+		return
+	}
+	w.endWatering(t)
+	w.lastDeep = t
+	w.deepUntil = t.Add(config.DeepWaterDuration + config.DeepWaterCooldown)
+	w.mu.Unlock()
+
+	slog.Info("start watering", "type", wateringDeep, "duration", config.DeepWaterDuration, "cooldown", config.DeepWaterCooldown)
+	w.pumpCommand(true)
+	time.AfterFunc(config.DeepWaterDuration, func() {
+		slog.Info("stop watering", "type", wateringDeep)
+		w.pumpCommand(false)
+	})
+}
+
+// deepCooldown reports whether top-ups are held off at t by a recent
+// deep soak. w.mu must be held.
+func (w *WaterController) deepCooldown(t time.Time) bool {
+	return t.Before(w.deepUntil)
+}
+
+// lastDeepWater returns when the last deep soak started, for saving.
+func (w *WaterController) lastDeepWater() time.Time {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastDeep
+}
+
+// restoreDeepWater restores the start of the last deep soak, and the
+// cooldown after it if that has not yet passed.
+func (w *WaterController) restoreDeepWater(last time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastDeep = last
+	if !last.IsZero() {
+		w.deepUntil = last.Add(config.DeepWaterDuration + config.DeepWaterCooldown)
+	}
+}
+
+// startDeepWater runs a deep soak every config.DeepWaterEvery at
+// config.DeepWaterAt.
+func (g *Gardener) startDeepWater() {
+	if config.DeepWaterEvery <= 0 || !config.AutoWater {
+		return
+	}
+	next := nextDeepWater(g.water.lastDeepWater(), now())
+	slog.Info("deep watering scheduled", "next", next, "every", config.DeepWaterEvery)
+	g.every("deep-water", deepWaterCheck, func(time.Time) {
+		t := now()
+		if t.Before(next) {
+			return
+		}
+		g.water.DeepWater(t)
+		next = nextDeepWater(t, t)
+		slog.Info("deep watering scheduled", "next", next)
+	})
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rustyeddy/otto/messenger"
+)
+
+// The messenger's Pub does not report delivery, so delivery of a
+// critical message is confirmed by the station receiving its own
+// message back from the broker on the topic it subscribes to.
+
+// pendingPub is a confirmed publish waiting to come back.
+type pendingPub struct {
+	topic   string
+	data    []byte
+	attempt int
+	timer   *time.Timer
+	done    func(error)
+}
+
+// errSuperseded resolves a confirmed publish that a newer one on the
+// same topic replaced before it was delivered.
+var errSuperseded = errors.New("superseded by a newer publish")
+
+// deliveries tracks the confirmed publishes in flight.
+type deliveries struct {
+	mu         sync.Mutex
+	pending    []*pendingPub
+	subscribed map[string]bool
+	stopped    bool
+}
+
+// publishConfirmed publishes data on topic and waits for the broker to
+// deliver it back. Each try is given config.DeliveryTimeout, and after
+// config.DeliveryRetries more tries an undelivered message raises an
+// alert. done, if not nil, is called with nil on delivery or the error.
+// A newer publish on the same topic supersedes one still pending, so a
+// retry never resends a stale command, such as an "on" after an "off".
+// Fire and forget sensor data keeps using publish.
+func (g *Gardener) publishConfirmed(topic string, data []byte, done func(error)) {
+	d := &g.deliveries
+	d.mu.Lock()
+	if config.DeliveryTimeout <= 0 || d.stopped {
+		d.mu.Unlock()
+		g.publish(topic, data)
+		if done != nil {
+			done(nil)
+		}
+		return
+	}
+	var superseded []*pendingPub
+	d.pending = slices.DeleteFunc(d.pending, func(p *pendingPub) bool {
+		if p.topic != topic {
+			return false
+		}
+		p.timer.Stop()
+		superseded = append(superseded, p)
+		return true
+	})
+	if !d.subscribed[topic] {
+		// Nothing here listens to it, so listen just for the echo.
+		d.mu.Unlock()
+		g.subscribe(topic, func(*messenger.Msg) error { return nil })
+		d.mu.Lock()
+	}
+	p := &pendingPub{topic: topic, data: data, done: done}
+	d.pending = append(d.pending, p)
+	g.armConfirmed(p)
+	d.mu.Unlock()
+	for _, old := range superseded {
+		slog.Debug("publish superseded", "topic", old.topic, "data", string(old.data), "by", string(data))
+		if old.done != nil {
+			old.done(errSuperseded)
+		}
+	}
+	g.publish(topic, data)
+}
+
+// stopDeliveries stops every retry timer and drops the pending
+// publishes, so none is resent behind the shutdown. Later confirmed
+// publishes are sent unconfirmed.
+func (g *Gardener) stopDeliveries() {
+	d := &g.deliveries
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stopped = true
+	for _, p := range d.pending {
+		p.timer.Stop()
+	}
+	d.pending = nil
+}
+
+// armConfirmed counts a try of p and starts its timeout. d.mu must be
+// held, but not while publishing, as a local messenger may deliver the
+// echo before Pub returns.
+func (g *Gardener) armConfirmed(p *pendingPub) {
+	p.attempt++
+	p.timer = time.AfterFunc(config.DeliveryTimeout, func() { g.deliveryTimedOut(p) })
+}
+
+func (g *Gardener) deliveryTimedOut(p *pendingPub) {
+	d := &g.deliveries
+	d.mu.Lock()
+	i := slices.Index(d.pending, p)
+	if i < 0 {
+		d.mu.Unlock()
+		return
+	}
+	if p.attempt <= config.DeliveryRetries {
+		slog.Warn("publish not confirmed, retrying", "topic", p.topic, "attempt", p.attempt, "timeout", config.DeliveryTimeout)
+		g.armConfirmed(p)
+		d.mu.Unlock()
+		g.publish(p.topic, p.data)
+		return
+	}
+	d.pending = slices.Delete(d.pending, i, i+1)
+	d.mu.Unlock()
+
+	err := fmt.Errorf("%s %q not delivered after %d attempts", p.topic, p.data, p.attempt)
+	g.Alert("publish_undelivered", err.Error())
+	if p.done != nil {
+		p.done(err)
+	}
+}
+
+// confirm resolves the oldest pending publish msg matches.
+func (g *Gardener) confirm(msg *messenger.Msg) {
+	d := &g.deliveries
+	d.mu.Lock()
+	i := slices.IndexFunc(d.pending, func(p *pendingPub) bool {
+		return p.topic == msg.Topic && bytes.Equal(p.data, msg.Data)
+	})
+	if i < 0 {
+		d.mu.Unlock()
+		return
+	}
+	p := d.pending[i]
+	d.pending = slices.Delete(d.pending, i, i+1)
+	p.timer.Stop()
+	d.mu.Unlock()
+
+	slog.Debug("publish confirmed", "topic", p.topic, "attempt", p.attempt)
+	if p.done != nil {
+		p.done(nil)
+	}
+}
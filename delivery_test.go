@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rustyeddy/otto/messenger"
+)
+
+// pendingData returns the payloads of the confirmed publishes in
+// flight.
+func pendingData(g *Gardener) []string {
+	d := &g.deliveries
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var data []string
+	for _, p := range d.pending {
+		data = append(data, p.topic+" "+string(p.data))
+	}
+	return data
+}
+
+func TestPublishConfirmedSupersedes(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config.DeliveryTimeout = time.Hour
+	config.DeliveryRetries = 3
+
+	g := &Gardener{}
+	var mu sync.Mutex
+	results := make(map[string]error)
+	done := func(name string) func(error) {
+		return func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			results[name] = err
+		}
+	}
+	g.publishConfirmed("c/pump", []byte("on"), done("on"))
+	g.publishConfirmed("c/valve", []byte("open"), done("open"))
+	g.publishConfirmed("c/pump", []byte("off"), done("off"))
+
+	if got, want := pendingData(g), []string{"c/valve open", "c/pump off"}; !slices.Equal(got, want) {
+		t.Errorf("pending = %q, want %q", got, want)
+	}
+	if err := results["on"]; !errors.Is(err, errSuperseded) {
+		t.Errorf("superseded on resolved with %v, want %v", err, errSuperseded)
+	}
+
+	// The stale "on" coming back late confirms nothing.
+	g.confirm(&messenger.Msg{Topic: "c/pump", Data: []byte("on")})
+	g.confirm(&messenger.Msg{Topic: "c/pump", Data: []byte("off")})
+	if got, want := pendingData(g), []string{"c/valve open"}; !slices.Equal(got, want) {
+		t.Errorf("pending = %q, want %q", got, want)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if err, ok := results["off"]; !ok || err != nil {
+		t.Errorf("off resolved %t with %v, want delivered", ok, err)
+	}
+}
+
+func TestStopDeliveries(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config.DeliveryTimeout = 20 * time.Millisecond
+	config.DeliveryRetries = 3
+
+	g := &Gardener{}
+	g.publishConfirmed("c/pump", []byte("on"), nil)
+	p := g.deliveries.pending[0]
+	g.stopDeliveries()
+	if got := pendingData(g); len(got) != 0 {
+		t.Errorf("pending after stop = %q, want none", got)
+	}
+	time.Sleep(5 * config.DeliveryTimeout)
+	g.deliveries.mu.Lock()
+	defer g.deliveries.mu.Unlock()
+	if p.attempt != 1 {
+		t.Errorf("publish tried %d times after stop, want no retries", p.attempt)
+	}
+}
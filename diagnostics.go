@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DeviceDiag is the read health of a single device as seen from its
+// ticker callback.
+type DeviceDiag struct {
+	LastRead          time.Time `json:"last_read"`
+	LastError         string    `json:"last_error"`
+	LastErrorTime     time.Time `json:"last_error_time"`
+	ConsecutiveErrors int       `json:"consecutive_errors"`
+	Interval          string    `json:"interval"`
+}
+
+// Diagnostics tracks DeviceDiag for every polled device and serves
+// them on /api/diagnostics.
+type Diagnostics struct {
+	mu      sync.Mutex
+	devices map[string]*DeviceDiag
+}
+
+func newDiagnostics() *Diagnostics {
+	return &Diagnostics{devices: make(map[string]*DeviceDiag)}
+}
+
+func (d *Diagnostics) get(name string) *DeviceDiag {
+	dd, ok := d.devices[name]
+	if !ok {
+		dd = &DeviceDiag{}
+		d.devices[name] = dd
+	}
+	return dd
+}
+
+// Register records the ticker interval the device is polled at.
+func (d *Diagnostics) Register(name string, interval time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.get(name).Interval = interval.String()
+}
+
+// ReadOK records a successful read and clears the error count.
+func (d *Diagnostics) ReadOK(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dd := d.get(name)
+	dd.LastRead = now()
+	dd.ConsecutiveErrors = 0
+}
+
+// ReadFailed records a failed read.
+func (d *Diagnostics) ReadFailed(name string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dd := d.get(name)
+	dd.LastError = err.Error()
+	dd.LastErrorTime = now()
+	dd.ConsecutiveErrors++
+}
+
+// Snapshot returns a copy of every device's diagnostics.
+func (d *Diagnostics) Snapshot() map[string]DeviceDiag {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	devs := make(map[string]DeviceDiag, len(d.devices))
+	for name, dd := range d.devices {
+		devs[name] = *dd
+	}
+	return devs
+}
+
+func (d *Diagnostics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(d.devices); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
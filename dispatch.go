@@ -0,0 +1,61 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/rustyeddy/otto/messenger"
+)
+
+// commandTopic is the wildcard subscription for device commands. A
+// message on c/<device>/set is routed to the handler registered for
+// <device> with Control.
+const commandTopic = "c/+/set"
+
+// Control registers h as the command handler for the named device. A
+// command topic from -device-command is subscribed to as well.
+func (g *Gardener) Control(name string, h messenger.MsgHandler) {
+	if g.controls == nil {
+		g.controls = make(map[string]messenger.MsgHandler)
+	}
+	g.controls[name] = func(msg *messenger.Msg) error {
+		g.commanded(name)
+		return h(msg)
+	}
+	if t, ok := config.DeviceCommands[name]; ok {
+		g.subscribe(t, g.controls[name])
+	}
+}
+
+// commanded counts a command for the named device, so that a rule's
+// timed off can tell something else has commanded it since.
+func (g *Gardener) commanded(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.commands == nil {
+		g.commands = make(map[string]uint64)
+	}
+	g.commands[name]++
+}
+
+// dispatchCommand routes a c/<device>/set message to the device's
+// command handler.
+func (g *Gardener) dispatchCommand(msg *messenger.Msg) error {
+	parts := strings.Split(msg.Topic, "/")
+	if len(parts) < 2 || parts[len(parts)-1] != "set" {
+		slog.Warn("malformed command topic", "topic", msg.Topic)
+		return nil
+	}
+	name := parts[len(parts)-2]
+
+	h, ok := g.controls[name]
+	if !ok {
+		if g.DeviceManager.GetDevice(name) != nil {
+			slog.Warn("command for device that is not controllable", "device", name, "topic", msg.Topic)
+		} else {
+			slog.Warn("command for unknown device", "device", name, "topic", msg.Topic)
+		}
+		return nil
+	}
+	return h(msg)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rustyeddy/devices/oled"
+)
+
+// Display is a small screen addressed by line. Clear and Printf change
+// what is to be shown and Draw shows it.
+type Display interface {
+	Clear()
+	Printf(line int, format string, args ...any)
+	Draw() error
+}
+
+// nullDisplay is the Display of a station without one.
+type nullDisplay struct{}
+
+func (nullDisplay) Clear()                     {}
+func (nullDisplay) Printf(int, string, ...any) {}
+func (nullDisplay) Draw() error                { return nil }
+
+// oledDisplay is the I2C OLED as a Display.
+type oledDisplay struct {
+	*oled.OLED
+}
+
+// oledLineHeight is the pixel height of a text line on the OLED.
+const oledLineHeight = 24
+
+// Draw sends the frame to the OLED, holding its bus.
+func (d oledDisplay) Draw() error {
+	return withBus(strconv.Itoa(displayBus), d.OLED.Draw)
+}
+
+func (d oledDisplay) Printf(line int, format string, args ...any) {
+	d.DrawString(0, 16+line*oledLineHeight, fmt.Sprintf(format, args...))
+}
+
+// displayLabels are the default, English, strings shown on the display,
+// keyed by what they label. -display-label replaces any of them.
+var displayLabels = map[string]string{
+	"low":         "Set low threshold",
+	"high":        "Set high threshold",
+	"soil":        "Soil",
+	"temperature": "Temp",
+	"humidity":    "Humidity",
+	"pressure":    "Pressure",
+	"soiltemp":    "Soil temp",
+	"pump":        "Pump",
+}
+
+// displayUnits are the units values are shown with. Temperature
+// follows config.TempUnit.
+var displayUnits = map[string]string{
+	"low":      "%",
+	"high":     "%",
+	"soil":     "%",
+	"humidity": "%",
+	"pressure": "hPa",
+}
+
+// validateDisplayType checks the -display flag.
+func validateDisplayType(t string) error {
+	switch t {
+	case "oled", "hd44780":
+		return nil
+	}
+	return fmt.Errorf("unknown display %q: want oled or hd44780", t)
+}
+
+// validateTempUnit checks the -temp-unit flag.
+func validateTempUnit(u string) error {
+	switch strings.ToUpper(u) {
+	case "C", "F":
+		return nil
+	}
+	return fmt.Errorf("unknown temperature unit %q: want C or F", u)
+}
+
+// displayLabel returns the display string for key.
+func displayLabel(key string) string {
+	if s, ok := config.DisplayLabels[key]; ok {
+		return s
+	}
+	if s, ok := displayLabels[key]; ok {
+		return s
+	}
+	return key
+}
+
+// displayValue formats the value for key with its unit, converting
+// temperatures, which are read in Celsius, when Fahrenheit is wanted.
+func displayValue(key string, v float64) string {
+	unit := displayUnits[key]
+	if key == "temperature" || key == "soiltemp" {
+		unit = "°C"
+		if strings.EqualFold(config.TempUnit, "F") {
+			v, unit = v*9/5+32, "°F"
+		}
+	}
+	return strings.TrimSpace(fmt.Sprintf("%5.1f %s", v, unit))
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// w1Devices is where the kernel w1-therm driver exposes 1-Wire sensors.
+const w1Devices = "/sys/bus/w1/devices"
+
+var (
+	errW1NotFound = errors.New("1-wire sensor not found")
+	errW1CRC      = errors.New("1-wire crc error")
+)
+
+// DS18B20 is a 1-Wire temperature sensor, typically a waterproof probe
+// in the root zone, identified by its ROM ID, e.g. 28-0316a2793cff.
+type DS18B20 struct {
+	id string
+}
+
+func newDS18B20(id string) *DS18B20 {
+	return &DS18B20{id: id}
+}
+
+func (d *DS18B20) Name() string {
+	return "ds18b20-" + d.id
+}
+
+// Get returns the temperature in degrees C.
+func (d *DS18B20) Get() (float64, error) {
+	buf, err := os.ReadFile(filepath.Join(w1Devices, d.id, "w1_slave"))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("%s: %w", d.id, errW1NotFound)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return parseW1Slave(d.id, string(buf))
+}
+
+// parseW1Slave parses the w1_slave file, which looks like:
+//
+//	72 01 4b 46 7f ff 0e 10 57 : crc=57 YES
+//	72 01 4b 46 7f ff 0e 10 57 t=23125
+func parseW1Slave(id, s string) (float64, error) {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) < 2 {
+		return 0, fmt.Errorf("%s: short read %q", id, s)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(lines[0]), "YES") {
+		return 0, fmt.Errorf("%s: %w", id, errW1CRC)
+	}
+	_, t, ok := strings.Cut(lines[1], "t=")
+	if !ok {
+		return 0, fmt.Errorf("%s: no temperature in %q", id, lines[1])
+	}
+	milli, err := strconv.Atoi(strings.TrimSpace(t))
+	if err != nil {
+		return 0, fmt.Errorf("%s: bad temperature %q", id, t)
+	}
+	return float64(milli) / 1000, nil
+}
+
+// initSoilTempSensors creates a DS18B20 for every configured ROM ID,
+// each publishing on d/soiltemp. The first one also supplies the soil
+// temperature used for moisture compensation.
+func (g *Gardener) initSoilTempSensors() {
+	for i, id := range config.DS18B20 {
+		d := newDS18B20(id)
+		g.addDevice(d)
+		interval := jitter(config.SoilTempInterval)
+		g.diag.Register(d.Name(), interval)
+		primary := i == 0
+		read := func(time.Time) { g.readSoilTemp(d, primary) }
+		g.addSensor(d.Name(), read)
+		g.startTicker(d.Name(), interval, read)
+	}
+}
+
+func (g *Gardener) readSoilTemp(d *DS18B20, primary bool) {
+	g.beat()
+	var v float64
+	var err error
+	g.reads.do(func() { v, err = d.Get() })
+	if err != nil {
+		g.diag.ReadFailed(d.Name(), err)
+		slog.Error("soil temperature read failed", "device", d.Name(), "error", err)
+		return
+	}
+	t := now()
+	v = round("soiltemp", calibrate(d.Name(), v))
+	g.diag.ReadOK(d.Name())
+	if !finite(v) {
+		g.anomaly(d.Name(), v)
+		return
+	}
+	slog.Info("soil temperature reading", "device", d.Name(), "value", v)
+	if primary {
+		g.readings.setSoilTemp(v, t)
+	}
+	g.writePoint("soiltemp", map[string]float64{"temperature": v}, t)
+	g.summary.Observe(d.Name(), v)
+
+	payload := map[string]any{"id": d.id, "temperature": v}
+	if config.ReadingTimestamps {
+		payload["time"] = t.Format(time.RFC3339)
+	}
+	if config.ReadingQuality {
+		payload["quality"] = qualityGood
+	}
+	jbuf, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("soil temperature marshal failed", "error", err)
+		return
+	}
+	g.publish(stateTopic(d.Name(), "d/soiltemp"), jbuf)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// efficiencyTopic carries the watering response after each watering.
+const efficiencyTopic = "d/water/efficiency"
+
+// WateringResponse is how much a watering raised soil moisture and how
+// long it took to show. A response falling over weeks can mean a
+// clogged emitter or a root-bound pot.
+type WateringResponse struct {
+	Start      float64   `json:"start"`
+	Peak       float64   `json:"peak"`
+	Delta      float64   `json:"delta"`
+	Runtime    float64   `json:"runtime_seconds"`
+	PerSecond  float64   `json:"per_second"`
+	Liters     float64   `json:"liters,omitempty"`
+	PerLiter   float64   `json:"per_liter,omitempty"`
+	TimeToPeak float64   `json:"time_to_peak_seconds"`
+	Time       time.Time `json:"time"`
+}
+
+// responseTracker follows one watering from the pump starting, through
+// every pump run until it settles, to the highest moisture seen within
+// config.EfficiencyWindow of the last run ending. A soak's pulses count
+// as one watering.
+type responseTracker struct {
+	g *Gardener
+
+	mu       sync.Mutex
+	tracking bool
+	start    float64
+	runtime  time.Duration
+	offAt    time.Time
+	peak     float64
+	peakAt   time.Time
+	settle   *time.Timer
+}
+
+// begin is called when the pump starts a watering.
+func (r *responseTracker) begin() {
+	if config.EfficiencyWindow <= 0 {
+		return
+	}
+	rd := r.g.readings.Snapshot()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.settle != nil {
+		// Another pulse of the same watering.
+		r.settle.Stop()
+		r.settle = nil
+		r.offAt = time.Time{}
+		return
+	}
+	if r.tracking || rd.SoilTime.IsZero() {
+		return
+	}
+	r.tracking = true
+	r.start, r.runtime = rd.Soil, 0
+	r.peak, r.peakAt = rd.Soil, time.Time{}
+}
+
+// ran is called when the pump stops after running for runtime.
+func (r *responseTracker) ran(runtime time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.tracking {
+		return
+	}
+	r.runtime += runtime
+	r.offAt = now()
+	if r.settle != nil {
+		r.settle.Stop()
+	}
+	r.settle = time.AfterFunc(config.EfficiencyWindow, r.finish)
+}
+
+// observe feeds a soil reading into the watering being followed.
+func (r *responseTracker) observe(v float64, t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.tracking && !r.offAt.IsZero() && v > r.peak {
+		r.peak, r.peakAt = v, t
+	}
+}
+
+// finish publishes the response once the soil has had time to settle.
+func (r *responseTracker) finish() {
+	defer r.g.recoverPanic("watering-response")
+	r.mu.Lock()
+	if !r.tracking || r.offAt.IsZero() {
+		r.mu.Unlock()
+		return
+	}
+	resp := WateringResponse{
+		Start:   r.start,
+		Peak:    r.peak,
+		Delta:   round("soil", r.peak-r.start),
+		Runtime: r.runtime.Seconds(),
+		Time:    now(),
+	}
+	if !r.peakAt.IsZero() {
+		resp.TimeToPeak = r.peakAt.Sub(r.offAt).Seconds()
+	}
+	runtime := r.runtime
+	r.tracking, r.settle, r.offAt = false, nil, time.Time{}
+	r.mu.Unlock()
+
+	if resp.Runtime > 0 {
+		resp.PerSecond = resp.Delta / resp.Runtime
+	}
+	if config.PumpFlowRate > 0 {
+		resp.Liters = config.PumpFlowRate * resp.Runtime / 60
+		resp.PerLiter = resp.Delta / resp.Liters
+	}
+	slog.Info("watering response", "delta", resp.Delta, "runtime", runtime,
+		"per_second", resp.PerSecond, "time_to_peak", resp.TimeToPeak)
+
+	r.g.metrics.Set("gardener_watering_response_per_second", resp.PerSecond)
+	r.g.writePoint("water_efficiency", map[string]float64{
+		"delta":      resp.Delta,
+		"runtime":    resp.Runtime,
+		"per_second": resp.PerSecond,
+	}, resp.Time)
+	jbuf, err := json.Marshal(resp)
+	if err != nil {
+		slog.Error("watering response marshal failed", "error", err)
+		return
+	}
+	r.g.publish(efficiencyTopic, jbuf)
+}
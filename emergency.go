@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/rustyeddy/otto/messenger"
+)
+
+const (
+	emergencyStopTopic  = "c/emergency/stop"
+	emergencyResetTopic = "c/emergency/reset"
+)
+
+// errEmergencyStop rejects every pump start while the emergency stop is
+// latched.
+var errEmergencyStop = errors.New("emergency stop latched")
+
+// EmergencyStop turns the pump and every other actuator off at once,
+// past the minimum runtime and any soak, and latches: nothing turns it
+// back on, automatically or by hand, until EmergencyReset. The state is
+// published on d/emergency.
+func (g *Gardener) EmergencyStop(reason string) {
+	if g.estop.Swap(true) {
+		return
+	}
+	g.actuatorsOff(0)
+	g.stopRules()
+	g.water.emergencyStopped()
+	g.Alert("emergency_stop", reason)
+	g.publish("d/emergency", []byte("stopped"))
+}
+
+// EmergencyReset releases a latched emergency stop.
+func (g *Gardener) EmergencyReset() {
+	if !g.estop.Swap(false) {
+		return
+	}
+	slog.Warn("emergency stop reset")
+	g.publish("d/emergency", []byte("clear"))
+}
+
+func (g *Gardener) emergencyStopped() bool {
+	return g.estop.Load()
+}
+
+func (g *Gardener) handleEmergencyStop(msg *messenger.Msg) error {
+	g.EmergencyStop("emergency stop on " + msg.Topic)
+	return nil
+}
+
+func (g *Gardener) handleEmergencyReset(msg *messenger.Msg) error {
+	g.EmergencyReset()
+	return nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rustyeddy/devices/vh400"
+	"github.com/rustyeddy/otto/messenger"
+)
+
+// emulatorTick is how often the simulated environment moves on.
+const emulatorTick = 5 * time.Second
+
+// EmulatorState is the simulated environment under -mock. Soil and
+// Temperature, once set, replace what the mock sensors read; soil then
+// changes by Drift every tick, plus Water while the pump runs, so the
+// dry, water, recover cycle can be shown on demand.
+type EmulatorState struct {
+	Soil        *float64 `json:"soil,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	Drift       float64  `json:"drift"`
+	Water       float64  `json:"water"`
+}
+
+// Emulator holds the EmulatorState, which can be changed over HTTP on
+// /api/emulator or on c/emulator/set.
+type Emulator struct {
+	g *Gardener
+
+	mu sync.Mutex
+	s  EmulatorState
+}
+
+func newEmulator(g *Gardener) *Emulator {
+	return &Emulator{g: g, s: EmulatorState{Drift: -0.5, Water: 3}}
+}
+
+// State returns a copy of the simulated state.
+func (e *Emulator) State() EmulatorState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.s
+}
+
+// update applies a JSON document of the fields to change.
+func (e *Emulator) update(data []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := e.s
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("bad emulator state %q: %w", data, err)
+	}
+	e.s = s
+	slog.Info("emulator state changed", "state", string(data))
+	return nil
+}
+
+// step moves the simulated soil on by one tick.
+func (e *Emulator) step() {
+	pump := e.g.readings.Snapshot().Pump
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.s.Soil == nil {
+		return
+	}
+	v := *e.s.Soil + e.s.Drift
+	if pump {
+		v += e.s.Water
+	}
+	v = min(max(v, 0), 100)
+	e.s.Soil = &v
+}
+
+// soil returns the simulated soil moisture, if one is set.
+func (e *Emulator) soil() (float64, bool) {
+	if e == nil {
+		return 0, false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.s.Soil == nil {
+		return 0, false
+	}
+	return *e.s.Soil, true
+}
+
+// applyEnv replaces the env fields the emulator forces, reporting
+// whether it did.
+func (e *Emulator) applyEnv(fields map[string]float64) bool {
+	if e == nil {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.s.Temperature != nil {
+		fields["temperature"] = *e.s.Temperature
+		return true
+	}
+	return false
+}
+
+// HandleMsg handles c/emulator/set.
+func (e *Emulator) HandleMsg(msg *messenger.Msg) error {
+	return e.update(msg.Data)
+}
+
+func (e *Emulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodPut:
+		var buf json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&buf); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := e.update(buf); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(e.State()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (g *Gardener) initEmulator() {
+	if !config.Mock {
+		return
+	}
+	g.emu = newEmulator(g)
+	g.Control("emulator", g.emu.HandleMsg)
+}
+
+func (g *Gardener) emulator(soil *vh400.VH400) {
+	g.goSafe("emulator", func() {
+		ticker := time.NewTicker(emulatorTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-g.ctx.Done():
+				return // Exit the goroutine when the station stops
+			case _ = <-ticker.C:
+				// Execute this code at each tick
+				g.emu.step()
+				v, err := soil.Pin.Get()
+				if err != nil {
+					slog.Error("emulator failure", "error", err)
+					continue
+				}
+				v += 0.02
+				soil.Pin.Set(v)
+			}
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/rustyeddy/devices"
+	"github.com/rustyeddy/devices/button"
+)
+
+// RotaryEncoder is a quadrature rotary encoder on two GPIO inputs, A
+// and B, with an optional push switch. Each rising edge on A is one
+// detent, clockwise when B is low.
+type RotaryEncoder struct {
+	name string
+	a    *button.Button
+	b    *button.Button
+	push *button.Button
+
+	mu     sync.Mutex
+	levelB bool
+
+	// OnTurn is called with +1 for a clockwise and -1 for an
+	// anticlockwise detent, OnPush when the switch is pressed.
+	OnTurn func(step int)
+	OnPush func()
+}
+
+// newRotaryEncoder creates an encoder on pins a and b, and a push
+// switch on push unless it is negative.
+func newRotaryEncoder(name string, a, b, push int) (*RotaryEncoder, error) {
+	e := &RotaryEncoder{name: name}
+
+	var err error
+	if e.a, err = button.New(name+"-a", a); err != nil {
+		return nil, err
+	}
+	if e.b, err = button.New(name+"-b", b); err != nil {
+		return nil, err
+	}
+	e.b.RegisterEventHandler(func(evt *devices.DeviceEvent) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		switch evt.Type {
+		case devices.DeviceEventRisingEdge:
+			e.levelB = true
+		case devices.DeviceEventFallingEdge:
+			e.levelB = false
+		}
+	})
+	e.a.RegisterEventHandler(func(evt *devices.DeviceEvent) {
+		if evt.Type != devices.DeviceEventRisingEdge || e.OnTurn == nil {
+			return
+		}
+		e.mu.Lock()
+		step := 1
+		if e.levelB {
+			step = -1
+		}
+		e.mu.Unlock()
+		e.OnTurn(step)
+	})
+
+	if push >= 0 {
+		if e.push, err = button.New(name+"-push", push); err != nil {
+			return nil, err
+		}
+		e.push.RegisterEventHandler(func(evt *devices.DeviceEvent) {
+			if evt.Type == devices.DeviceEventRisingEdge && e.OnPush != nil {
+				e.OnPush()
+			}
+		})
+	}
+	return e, nil
+}
+
+func (e *RotaryEncoder) Name() string {
+	return e.name
+}
+
+// encoderSettings are the settings the encoder cycles through with a
+// push, in order.
+var encoderSettings = []string{"low", "high"}
+
+func (g *Gardener) initEncoder() {
+	if config.EncoderA < 0 || config.EncoderB < 0 {
+		return
+	}
+	e, err := newRotaryEncoder("encoder", config.EncoderA, config.EncoderB, config.EncoderPush)
+	if err != nil {
+		panic(err)
+	}
+	e.OnTurn = func(step int) {
+		defer g.recoverPanic("encoder")
+		g.adjustSetting(float64(step) * config.EncoderStep)
+	}
+	e.OnPush = func() {
+		defer g.recoverPanic("encoder")
+		g.mu.Lock()
+		g.selected = (g.selected + 1) % len(encoderSettings)
+		g.mu.Unlock()
+		g.showSetting()
+	}
+	g.addDevice(e)
+	g.encoder = e
+}
+
+// adjustSetting adds delta to the setting selected on the encoder.
+func (g *Gardener) adjustSetting(delta float64) {
+	low, high := g.water.Thresholds()
+	g.mu.Lock()
+	switch encoderSettings[g.selected] {
+	case "low":
+		low += delta
+	case "high":
+		high += delta
+	}
+	g.mu.Unlock()
+	g.water.SetThresholds(low, high)
+	g.showSetting()
+}
+
+// showSetting puts the selected setting and its value on the display.
+func (g *Gardener) showSetting() {
+	g.mu.Lock()
+	name := encoderSettings[g.selected]
+	g.mu.Unlock()
+
+	low, high := g.water.Thresholds()
+	value := low
+	if name == "high" {
+		value = high
+	}
+	slog.Info("encoder setting", "setting", name, "value", value)
+
+	g.holdDisplay()
+	g.display.Clear()
+	g.display.Printf(0, "%s", displayLabel(name))
+	g.display.Printf(1, "%s", displayValue(name, value))
+	if err := g.display.Draw(); err != nil {
+		slog.Error("display draw failed", "error", err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rustyeddy/devices/bme280"
+)
+
+// envSpec is one env sensor: its name, I2C address and topic.
+type envSpec struct {
+	name  string
+	addr  int
+	topic string
+}
+
+// envSensors returns the env sensors to create. Without -env-sensor
+// there is one, "env" at envAddr publishing on d/env; otherwise each
+// name=address entry publishes on d/env/<name>.
+func envSensors() ([]envSpec, error) {
+	if len(config.EnvSensors) == 0 {
+		return []envSpec{{name: "env", addr: envAddr, topic: stateTopic("env", "d/env")}}, nil
+	}
+	var specs []envSpec
+	for _, s := range config.EnvSensors {
+		name, addr, ok := strings.Cut(s, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("env sensor %q: expected name=address", s)
+		}
+		a, err := strconv.ParseInt(addr, 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("env sensor %q: bad address %q", s, addr)
+		}
+		specs = append(specs, envSpec{name: name, addr: int(a), topic: stateTopic(name, "d/env/"+name)})
+	}
+	return specs, nil
+}
+
+// readEnv reads an env sensor, returning its fields by name.
+func readEnv(env *bme280.BME280) (map[string]float64, error) {
+	resp, err := env.Get()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]float64{
+		"temperature": resp.Temperature,
+		"humidity":    resp.Humidity,
+		"pressure":    resp.Pressure,
+	}, nil
+}
+
+// filterEnv returns the env fields that are plausible. One bad channel
+// no longer scraps the whole sample: the others still flow, and the
+// bad one is dropped or, with config.HoldLastValid, held at its last
+// valid value from held. The second result reports whether any was.
+func (g *Gardener) filterEnv(fields, held map[string]float64) (map[string]float64, bool) {
+	valid := make(map[string]float64, len(fields))
+	wasHeld := false
+	for name, v := range fields {
+		if plausible(name, v) {
+			valid[name] = v
+			continue
+		}
+		g.anomaly(name, v)
+		if last, ok := held[name]; ok && config.HoldLastValid {
+			valid[name] = last
+			wasHeld = true
+		}
+	}
+	return valid, wasHeld
+}
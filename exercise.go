@@ -0,0 +1,63 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+// exerciseCheck is how often the pump is checked for having sat idle.
+const exerciseCheck = time.Hour
+
+// Exercise briefly runs the pump as maintenance, so a pump that sits
+// idle for weeks does not seize. The run is not counted as watering.
+// It does nothing if the pump is already running.
+func (p *Pump) Exercise(d time.Duration) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running || p.soakStop != nil || p.g.emergencyStopped() {
+		return nil
+	}
+	p.exercising = true
+	slog.Info("pump maintenance run", "duration", d, "idle", time.Since(p.lastRan))
+	if err := p.on(); err != nil {
+		p.exercising = false
+		return err
+	}
+	time.AfterFunc(d, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if !p.exercising {
+			return
+		}
+		if err := p.off(); err != nil {
+			slog.Error("pump maintenance off failed", "error", err)
+		}
+	})
+	return nil
+}
+
+// idle returns how long since the pump last ran.
+func (p *Pump) idle() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running {
+		return 0
+	}
+	return time.Since(p.lastRan)
+}
+
+// startPumpExercise runs the pump for config.PumpExerciseRun whenever
+// it has not run for config.PumpExercise.
+func (g *Gardener) startPumpExercise() {
+	if g.pump == nil || config.PumpExercise <= 0 {
+		return
+	}
+	g.every("pump-exercise", exerciseCheck, func(time.Time) {
+		if g.pump.idle() < config.PumpExercise {
+			return
+		}
+		if err := g.pump.Exercise(config.PumpExerciseRun); err != nil {
+			slog.Error("pump maintenance run failed", "error", err)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Feature is one optional subsystem, whether this station has it on
+// and, if so, its key settings.
+type Feature struct {
+	Name    string         `json:"name"`
+	Enabled bool           `json:"enabled"`
+	Params  map[string]any `json:"params,omitempty"`
+}
+
+// features lists the optional subsystems of the resolved config, in a
+// fixed order.
+func (g *Gardener) features() []Feature {
+	f := func(name string, enabled bool, params ...any) Feature {
+		ft := Feature{Name: name, Enabled: enabled}
+		if enabled && len(params) > 0 {
+			ft.Params = make(map[string]any)
+			for i := 0; i+1 < len(params); i += 2 {
+				ft.Params[params[i].(string)] = params[i+1]
+			}
+		}
+		return ft
+	}
+	return []Feature{
+		f("mock", config.Mock),
+		f("soil", config.EnableSoil, "modes", config.SoilModes.String()),
+		f("env", config.EnableEnv, "sensors", len(g.envs)),
+		f("soil-temp", len(config.DS18B20) > 0 || config.SoilTempTopic != "", "probes", len(config.DS18B20), "topic", config.SoilTempTopic, "coeff", config.SoilTempCoeff),
+		f("buttons", config.EnableButtons, "coalesce", config.ButtonCoalesce.String()),
+		f("encoder", config.EncoderA >= 0 && config.EncoderB >= 0),
+		f("display", config.EnableDisplay, "type", config.DisplayType, "pages", len(g.pages), "rotate", config.DisplayRotate.String()),
+		f("pump", config.EnablePump, "max_run_seconds", config.PumpMaxRunSeconds, "feedback", config.PumpFeedbackPin >= 0),
+		f("auto-water", config.AutoWater, "low", config.LowThreshold, "high", config.HighThreshold, "hysteresis", config.Hysteresis.String()),
+		f("deep-water", config.DeepWaterEvery > 0, "every", config.DeepWaterEvery.String(), "at", config.DeepWaterAt, "duration", config.DeepWaterDuration.String()),
+		f("profile", true, "profile", g.water.Profile().Name, "profiles", len(g.profiles)),
+		f("zones", len(config.ZoneValves) > 0, "zones", len(config.ZoneValves), "busy", config.ZoneBusy),
+		f("weather", g.weather != nil, "et0_topic", config.ET0Topic, "rain_topic", config.RainTopic),
+		f("rtc", config.RTCBus != "", "bus", config.RTCBus),
+		f("persistence", config.DataDir != "", "dir", config.DataDir),
+		f("influx", config.InfluxURL != "", "url", config.InfluxURL, "bucket", config.InfluxBucket),
+		f("metrics-push", config.MetricsPush != "", "url", config.MetricsPush, "interval", config.MetricsPushInterval.String()),
+		f("rules", config.RulesFile != "", "file", config.RulesFile),
+		f("bridge", len(g.bridge) > 0, "rules", len(g.bridge)),
+		f("offline-policy", config.OfflinePolicy != offlineNone, "policy", config.OfflinePolicy, "after", config.OfflineAfter.String()),
+		f("publish-queue", g.pubq != nil, "size", config.PublishQueue),
+		f("calibration", len(config.Calibrate) > 0, "calibrate", config.Calibrate.String()),
+	}
+}
+
+// logFeatures logs the startup banner: a line for every enabled
+// feature with its settings, then the disabled ones together.
+func (g *Gardener) logFeatures() {
+	var off []string
+	for _, ft := range g.features() {
+		if !ft.Enabled {
+			off = append(off, ft.Name)
+			continue
+		}
+		args := make([]any, 0, 2*len(ft.Params)+2)
+		args = append(args, "feature", ft.Name)
+		keys := slices.Sorted(maps.Keys(ft.Params))
+		for _, k := range keys {
+			args = append(args, k, ft.Params[k])
+		}
+		slog.Info("feature enabled", args...)
+	}
+	slog.Info("features disabled", "features", strings.Join(off, ","))
+}
+
+// serveFeatures serves the features on /api/features.
+func (g *Gardener) serveFeatures(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(g.features()); err != nil {
+		slog.Error("features encode failed", "error", err)
+	}
+}
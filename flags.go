@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stringList is a comma separated flag value that may also be given
+// more than once.
+type stringList []string
+
+func (l *stringList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
+
+// durationMap is a comma separated list of name=duration flag values,
+// e.g. "on=100ms,off=100ms".
+type durationMap map[string]time.Duration
+
+func (m *durationMap) String() string {
+	if m == nil {
+		return ""
+	}
+	var parts []string
+	for name, d := range *m {
+		parts = append(parts, name+"="+d.String())
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (m *durationMap) Set(v string) error {
+	if *m == nil {
+		*m = make(durationMap)
+	}
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, dur, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("%q: expected name=duration", part)
+		}
+		d, err := time.ParseDuration(dur)
+		if err != nil {
+			return fmt.Errorf("%q: %w", part, err)
+		}
+		(*m)[name] = d
+	}
+	return nil
+}
+
+// aliasMap is a comma separated list of topic=alias flag values, e.g.
+// "d/soil=garden/soil,d/soil=soil". A topic may have several aliases.
+type aliasMap map[string][]string
+
+func (m *aliasMap) String() string {
+	if m == nil {
+		return ""
+	}
+	var parts []string
+	for topic, aliases := range *m {
+		for _, a := range aliases {
+			parts = append(parts, topic+"="+a)
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (m *aliasMap) Set(v string) error {
+	if *m == nil {
+		*m = make(aliasMap)
+	}
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		topic, alias, ok := strings.Cut(part, "=")
+		if !ok || topic == "" || alias == "" {
+			return fmt.Errorf("%q: expected topic=alias", part)
+		}
+		(*m)[topic] = append((*m)[topic], alias)
+	}
+	return nil
+}
+
+// stringMap is a comma separated list of key=value flag values, e.g.
+// "soil=Boden,humidity=Feuchte".
+type stringMap map[string]string
+
+func (m *stringMap) String() string {
+	if m == nil {
+		return ""
+	}
+	var parts []string
+	for k, v := range *m {
+		parts = append(parts, k+"="+v)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (m *stringMap) Set(v string) error {
+	if *m == nil {
+		*m = make(stringMap)
+	}
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		k, val, ok := strings.Cut(part, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("%q: expected key=value", part)
+		}
+		(*m)[k] = val
+	}
+	return nil
+}
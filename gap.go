@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// gapMarkers are the payloads -gap-marker may select. Consumers that
+// see one should break the line on a chart rather than interpolate
+// across the outage.
+var gapMarkers = map[string]string{
+	"null":   "null",
+	"nan":    "NaN",
+	"object": `{"gap":true}`,
+}
+
+func validateGapMarker(m string) error {
+	if _, ok := gapMarkers[m]; m != "" && !ok {
+		return fmt.Errorf("unknown gap marker %q: want null, nan or object", m)
+	}
+	return nil
+}
+
+// dataTopics are the topics readings are published on.
+func (g *Gardener) dataTopics() []string {
+	var topics []string
+	if config.PublishTopics {
+		if config.EnableSoil {
+			topics = append(topics, stateTopic("soil", "d/soil"))
+		}
+		if config.EnableEnv {
+			specs, _ := envSensors()
+			for _, spec := range specs {
+				topics = append(topics, spec.topic)
+			}
+		}
+		seen := make(map[string]bool)
+		for _, id := range config.DS18B20 {
+			t := stateTopic(newDS18B20(id).Name(), "d/soiltemp")
+			if !seen[t] {
+				seen[t] = true
+				topics = append(topics, t)
+			}
+		}
+	}
+	if config.PublishState {
+		topics = append(topics, "d/state")
+	}
+	return topics
+}
+
+// publishGapMarkers marks the break in every data topic after the
+// station connects to the broker.
+func (g *Gardener) publishGapMarkers() {
+	marker, ok := gapMarkers[config.GapMarker]
+	if !ok {
+		return
+	}
+	for _, topic := range g.dataTopics() {
+		g.publish(topic, []byte(marker))
+	}
+	slog.Info("published data gap markers", "marker", config.GapMarker)
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	gomqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/rustyeddy/devices"
 	"github.com/rustyeddy/devices/bme280"
 	"github.com/rustyeddy/devices/button"
@@ -23,13 +30,61 @@ type Gardener struct {
 	*station.DeviceManager // is this really needed?
 
 	soil    *vh400.VH400
-	env     *bme280.BME280
-	pump    *relay.Relay
+	envs    []*bme280.BME280
+	pump    *Pump
+	zones   *Zones
 	on      *button.Button
 	off     *button.Button
-	display *oled.OLED
+	display Display
+	encoder *RotaryEncoder
+	led     *RGBLED
 
-	Done chan any
+	diag     *Diagnostics
+	metrics  *Metrics
+	influx   *InfluxSink
+	pubq     *publishQueue
+	response *responseTracker
+	weather  *weatherFeed
+	pages    []DisplayEntry
+	bridge   []*BridgeRule
+	summary  *summarizer
+	readings readingCache
+	water    *WaterController
+	controls map[string]messenger.MsgHandler
+	sensors  map[string]func(time.Time)
+	names    map[string]bool
+	initErrs []error // setup problems for Init to return
+	reads    readLimiter
+	pressure *pressureTrend
+	rules    []*Rule
+	profiles map[string]*Profile
+	health   health
+	estop    atomic.Bool
+	stopping atomic.Bool    // set once Stop begins
+	running  sync.WaitGroup // goroutines started by goSafe
+	emu      *Emulator
+	started  time.Time
+
+	actuators  []actuator
+	deliveries deliveries
+	link       *brokerLink
+	mqttClient gomqtt.Client
+	subs       subscriptions
+
+	mu       sync.Mutex
+	selected int       // the encoder's selected setting
+	lastDump time.Time // when the state was last dumped on request
+
+	displayHeld time.Time // the display pages wait until then
+	tickers     []*deviceTicker
+	commands    map[string]uint64 // commands seen by device, see commanded
+
+	// ctx is cancelled when Stop begins, ending every background
+	// goroutine, and Done is closed once Stop has finished.
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+	Done     chan any
 }
 
 func (g *Gardener) GetDeviceManager() *station.DeviceManager {
@@ -39,139 +94,383 @@ func (g *Gardener) GetDeviceManager() *station.DeviceManager {
 	return g.DeviceManager
 }
 
-var (
-	pinmap = map[string]int{
-		"on":   17,
-		"off":  27,
-		"soil": 22,
-		"pump": 5,
-		"env":  6,
+func (g *Gardener) GetStationManager() *station.StationManager {
+	if g.StationManager == nil {
+		g.StationManager = station.NewStationManager()
 	}
-)
+	return g.StationManager
+}
+
+func (g *Gardener) GetServer() *server.Server {
+	if g.Server == nil {
+		g.Server = server.GetServer()
+	}
+	return g.Server
+}
 
-func (g *Gardener) Init() {
+// addDevice adds d to the device manager. A device with the name of
+// one already added is left out and reported by Init, since it would
+// silently replace the first and their readings would share topics.
+func (g *Gardener) addDevice(d any) {
+	if n, ok := d.(interface{ Name() string }); ok {
+		name := n.Name()
+		if g.names == nil {
+			g.names = make(map[string]bool)
+		}
+		if g.names[name] {
+			g.initErrs = append(g.initErrs, fmt.Errorf("duplicate device name %q", name))
+			return
+		}
+		g.names[name] = true
+	}
+	g.GetDeviceManager().Add(d)
+}
+
+// Init sets the station up. It returns an error for a setup that must
+// not run, such as two devices with the same name.
+func (g *Gardener) Init() error {
 	g.Messenger = messenger.GetMessenger()
+	g.initMQTT()
 	g.DeviceManager = g.GetDeviceManager()
-	g.StationManager = station.NewStationManager()
-	g.Server = server.GetServer()
+	g.StationManager = g.GetStationManager()
+	g.Server = g.GetServer()
 	g.Done = make(chan any)
+	g.ctx, g.cancel = context.WithCancel(context.Background())
+	g.started = time.Now()
+	g.diag = newDiagnostics()
+	g.summary = newSummarizer()
+	g.reads = newReadLimiter(config.MaxConcurrentReads)
+	g.initMetrics()
+	if config.PublishQueue > 0 {
+		g.pubq = newPublishQueue(g, config.PublishQueue)
+	}
+	g.initInflux()
+	g.water = newWaterController(g)
+	g.initProfiles()
+	g.response = &responseTracker{g: g}
+	g.initWeather()
+	g.initBridge()
+	g.restoreState()
 
-	g.initButtons()
-	g.initPump()
-	g.initEnv()
-	g.initDisplay()
-	g.InitSoil()
+	if err := validatePins(pinClaims()); err != nil {
+		panic(err)
+	}
+	g.display = nullDisplay{}
+	g.initSubsystems()
+	g.water.startGrace()
+	g.logFeatures()
+	g.InitApp()
+	return errors.Join(g.initErrs...)
 }
 
 func (g *Gardener) initButtons() {
+	slog.Info("button input mode", "mode", gpioMode(), "poll", config.GPIOPoll)
+	manual := coalesce(config.ButtonCoalesce, func(on bool) {
+		defer g.recoverPanic("buttons")
+		g.water.Manual(on)
+	})
 	var err error
-	g.on, err = button.New("on", pinmap["on"])
+	g.on, err = button.New("on", config.Pins.pin("on"))
 	if err != nil {
 		panic(err)
 	}
-	g.DeviceManager.Add(g.on)
-	g.on.RegisterEventHandler(func(evt *devices.DeviceEvent) {
+	g.addDevice(g.on)
+	g.onEvent("on", g.inputSource("on", g.on), func(evt *devices.DeviceEvent) {
 		switch evt.Type {
 		case devices.DeviceEventRisingEdge:
 			slog.Info("button pressed", "button", "on", "action", "pump_on")
-			g.Messenger.Pub("d/on", []byte("on"))
+			g.publish("d/on", []byte("on"))
+			manual(true)
 		}
 	})
 
-	g.off, err = button.New("off", pinmap["off"])
+	g.off, err = button.New("off", config.Pins.pin("off"))
 	if err != nil {
 		panic(err)
 	}
-	g.DeviceManager.Add(g.off)
-	g.off.RegisterEventHandler(func(evt *devices.DeviceEvent) {
+	g.addDevice(g.off)
+	g.onEvent("off", g.inputSource("off", g.off), func(evt *devices.DeviceEvent) {
 		switch evt.Type {
 		case devices.DeviceEventRisingEdge:
 			slog.Info("button pressed", "button", "off", "action", "pump_off")
-			g.Messenger.Pub("d/off", []byte("off"))
+			g.publish("d/off", []byte("off"))
+			manual(false)
 		}
 	})
 }
 
 func (g *Gardener) InitSoil() {
 	var err error
-	g.soil, err = vh400.New("soil", pinmap["soil"])
+	g.soil, err = vh400.New("soil", config.Pins.pin("soil"))
 	if err != nil {
 		panic(err)
 	}
-	g.DeviceManager.Add(g.soil)
+	g.addDevice(g.soil)
+	mode := soilMode("soil")
+	slog.Info("soil sensor mode", "device", "soil", "mode", mode)
+	interval := jitter(soilInterval)
+	g.diag.Register("soil", interval)
+	warm := newWarmup("soil", config.SoilWarmup)
+	band := &deadband{width: config.SoilDeadband}
+	var logged changeLog
 	cb := func(t time.Time) {
-		value, err := g.soil.Get()
+		defer g.recoverPanic("soil")
+		g.beat()
+		var value float64
+		var err error
+		g.reads.do(func() { value, err = readSoil(mode, g.soil) })
+		t = now() // the sample is as old as its read, not its tick
+		value = calibrate("soil", value)
+		if v, ok := g.emu.soil(); ok && err == nil {
+			value = v
+		}
 		if err != nil {
+			g.diag.ReadFailed("soil", err)
 			slog.Error("soil sensor read failed", "error", err)
 			return
 		}
-		slog.Info("soil moisture reading", "value", value)
-		g.Messenger.Pub("d/soil", []byte(fmt.Sprintf("%5.2f", value)))
+		g.diag.ReadOK("soil")
+		if !warm.ready(t) {
+			return
+		}
+		if !plausible("soil", value) {
+			g.anomaly("soil", value)
+			last := g.readings.Snapshot()
+			if !config.HoldLastValid || last.SoilTime.IsZero() {
+				return
+			}
+			value = last.SoilRaw
+		}
+		raw := round("soil", value)
+		value, compensated := g.compensateSoil(value)
+		value = round("soil", value)
+		if !finite(value) {
+			// Compensation went wrong; never publish NaN.
+			g.anomaly("soil", value)
+			last := g.readings.Snapshot()
+			if !config.HoldLastValid || last.SoilTime.IsZero() {
+				return
+			}
+			value = last.Soil
+		}
+		slog.Log(context.Background(), logged.level(map[string]float64{"soil": value}),
+			"soil moisture reading", "value", value, "raw", raw)
+		g.readings.setSoil(value, raw, t)
+		g.response.observe(value, t)
+		g.summary.Observe("soil", value)
+		g.showMoisture(value)
+		g.writePoint("soil", map[string]float64{"value": value, "raw": raw}, t)
+		// Every reading goes to the controller, so a steady dry soil
+		// still counts towards config.WaterConfirm; within the band it
+		// sees the value last acted on.
+		acted, changed := band.filter(value)
+		// The final reading on shutdown is published only, so it
+		// cannot start the pump behind the actuators going off.
+		if !g.stopping.Load() {
+			g.water.Update(acted, t)
+		}
+		if config.PublishTopics && (changed || !config.SoilPublishOnChange) {
+			topic := stateTopic("soil", "d/soil")
+			decimals, _ := precision("soil")
+			g.publish(topic, []byte(fmt.Sprintf("%5.*f", decimals, value)))
+			if compensated {
+				g.publish(topic+"/raw", []byte(fmt.Sprintf("%5.*f", decimals, raw)))
+			}
+		}
 	}
-	g.soil.StartTicker(10*time.Second, &cb)
+	g.addSensor("soil", cb)
+	g.startTicker("soil", interval, cb)
 }
 
 func (g *Gardener) initEnv() {
-	var err error
-	g.env, err = bme280.New("env", "/dev/i2c-1", 0x76)
+	specs, err := envSensors()
+	if err != nil {
+		panic(err)
+	}
+	g.pressure = &pressureTrend{window: config.PressureTrendWindow}
+	for i, spec := range specs {
+		g.initEnvSensor(spec, i == 0)
+	}
+}
+
+// initEnvSensor creates one BME280 publishing on spec.topic. Only the
+// primary sensor feeds the readings, the daily summary under the plain
+// field names and the pressure trend.
+func (g *Gardener) initEnvSensor(spec envSpec, primary bool) {
+	env, err := bme280.New(spec.name, envBus, spec.addr)
 	if err != nil {
 		panic(err)
 	}
-	g.DeviceManager.Add(g.env)
+	g.addDevice(env)
+	g.envs = append(g.envs, env)
+	name := spec.name
+	interval := jitter(envInterval)
+	g.diag.Register(name, interval)
+	warm := newWarmup(name, config.EnvWarmup)
+	held := make(map[string]float64)
+	var logged changeLog
 	ticker := func(t time.Time) {
-		resp, err := g.env.Get()
+		defer g.recoverPanic(name)
+		g.beat()
+		var raw map[string]float64
+		var err error
+		tries := 0
+		g.reads.do(func() {
+			tries, err = withBusTries(envBus, func() error {
+				var rerr error
+				raw, rerr = readEnv(env)
+				return rerr
+			})
+		})
+		t = now()
+		if err == nil {
+			calibrateFields(name, raw)
+		}
+		quality := qualityGood
+		if tries > 1 {
+			quality = qualityRetried
+		}
+		if err == nil && g.emu.applyEnv(raw) {
+			quality = qualityEstimated
+		}
 		if err != nil {
-			slog.Error("env sensor read failed", "error", err)
+			g.diag.ReadFailed(name, err)
+			slog.Error("env sensor read failed", "device", name, "error", err)
+			return
+		}
+		g.diag.ReadOK(name)
+		if !warm.ready(t) {
+			return
+		}
+		level := logged.level(raw)
+		slog.Log(context.Background(), level, "env sensor reading",
+			"device", name,
+			"temperature", raw["temperature"],
+			"humidity", raw["humidity"],
+			"pressure", raw["pressure"])
+		fields, wasHeld := g.filterEnv(raw, held)
+		if wasHeld {
+			quality = qualityHeld
+		}
+		if len(fields) == 0 {
+			g.diag.ReadFailed(name, errors.New("no valid env fields"))
+			return
+		}
+		roundFields(fields)
+		for field, v := range fields {
+			held[field] = v
+			if primary {
+				g.summary.Observe(field, v)
+			} else {
+				g.summary.Observe(name+"_"+field, v)
+			}
+		}
+		g.writePoint(name, fields, t)
+		if primary {
+			g.readings.setEnv(fields, t)
+			if p, ok := fields["pressure"]; ok {
+				g.updatePressureTrend(t, p)
+			}
+		}
+		if !config.PublishTopics {
 			return
 		}
-		slog.Info("env sensor reading",
-			"temperature", resp.Temperature,
-			"humidity", resp.Humidity,
-			"pressure", resp.Pressure)
 
-		jbuf, err := resp.JSON()
+		jbuf, err := json.Marshal(stamped(fields, t, quality))
 		if err != nil {
 			slog.Error("env sensor marshal failed", "error", err)
 			return
 		}
-		slog.Info("env sensor json", "data", string(jbuf))
-		g.Messenger.Pub("d/env", jbuf)
+		slog.Log(context.Background(), level, "env sensor json", "device", name, "data", string(jbuf))
+		g.publish(spec.topic, jbuf)
 	}
-	g.env.StartTicker(10*time.Second, &ticker)
+	g.addSensor(name, ticker)
+	g.startTicker(name, interval, ticker)
 }
 
 func (g *Gardener) initPump() {
-	var err error
-	g.pump, err = relay.New("pump", pinmap["pump"])
+	r, err := relay.New("pump", config.Pins.pin("pump"))
 	if err != nil {
 		panic(err)
 	}
-	g.Messenger.Sub("c/pump", g.pump.HandleMsg)
+	g.pump = newPump(g, r)
+	g.addActuator("pump", g.pump.ForceOff)
+	if config.PumpFeedbackPin >= 0 {
+		if err := g.pump.initFeedback(config.PumpFeedbackPin); err != nil {
+			panic(err)
+		}
+	}
+	g.subscribe("c/pump", g.pump.HandleMsg)
+	g.subscribe("c/pump/test", g.handlePumpTest)
+	g.Control("pump", g.pump.HandleMsg)
 }
 
 func (g *Gardener) initDisplay() {
-	display, err := oled.New("c/lcd", 0x27, 1)
-	if err != nil {
-		panic(err)
+	switch config.DisplayType {
+	case "hd44780":
+		lcd, err := newHD44780("c/lcd", fmt.Sprintf("/dev/i2c-%d", displayBus), displayAddr, lcdCols, lcdRows)
+		if err != nil {
+			panic(err)
+		}
+		g.display = lcd
+		g.addDevice(lcd)
+	default:
+		var display *oled.OLED
+		err := withBus(strconv.Itoa(displayBus), func() (err error) {
+			display, err = oled.New("c/lcd", displayAddr, displayBus)
+			return err
+		})
+		if err != nil {
+			panic(err)
+		}
+		display.Clear()
+		g.display = oledDisplay{display}
+		g.addDevice(display)
 	}
-	display.Clear()
-
-	// Register devices
-	g.DeviceManager.Add(display)
+	g.initDisplayPages()
 }
 
 func (g *Gardener) Start() {
-	err := g.Messenger.Connect()
-	if err != nil {
-		slog.Error("gardener failed to connect to broker ", "error", err)
-		return
-	}
+	g.startServer()
 
+	// The subscriptions are made on the broker whenever the client
+	// connects, so they can be set up before it has.
 	topics := []string{"soil", "env", "on", "off", "pump", "display"}
 	for _, topic := range topics {
-		g.Sub(topic, g.MsgHandler)
+		g.subscribe(topic, g.MsgHandler)
 	}
-	if config.Mock {
+	g.subscribe(commandTopic, g.dispatchCommand)
+	g.subscribe(stateGetTopic, g.handleStateGet)
+	g.subscribe(emergencyStopTopic, g.handleEmergencyStop)
+	g.subscribe(emergencyResetTopic, g.handleEmergencyReset)
+	g.subscribe("c/profile", g.handleProfile)
+
+	// Without the broker the station still waters on its own
+	// schedules, and keeps trying to connect in the background.
+	switch err := g.connectBroker(); {
+	case err == nil:
+		g.brokerConnected()
+	case errors.Is(err, errBrokerAuth):
+		slog.Error("gardener running without the broker", "error", err)
+	default:
+		slog.Error("gardener failed to connect to broker, retrying in the background", "error", err)
+		g.connectInBackground()
+	}
+	g.startBrokerLink()
+	g.initSoilTemp()
+	g.subscribeWeather()
+	g.startBridge()
+	g.startDisplayPages()
+	g.startStatePublisher()
+	g.startSummary()
+	g.startPruner()
+	g.startQuantiles()
+	g.startStateSaver()
+	g.startPumpExercise()
+	g.startDeepWater()
+	g.startMetricsPush()
+	g.startRules()
+	if config.Mock && config.EnableSoil {
 		md := g.DeviceManager.GetDevice("soil")
 		soil := md.(*vh400.VH400)
 		g.emulator(soil)
@@ -199,29 +498,16 @@ func (g *Gardener) MsgHandler(msg *messenger.Msg) error {
 	return nil
 }
 
+// Stop shuts the station down in logged phases and then closes Done.
+// It is safe to call more than once, and from several goroutines; only
+// the first call shuts down, and every call returns once it has.
 func (g *Gardener) Stop() {
-	// Implement stop logic if needed
-	g.Done <- true
-}
-
-func (g *Gardener) emulator(soil *vh400.VH400) {
-	ticker := time.NewTicker(5 * time.Second)
-
-	go func() {
-		for {
-			select {
-			case <-g.Done:
-				return // Exit the goroutine when done signal is received
-			case _ = <-ticker.C:
-				// Execute this code at each tick
-				v, err := soil.Pin.Get()
-				if err != nil {
-					slog.Error("emulator failure", "error", err)
-					continue
-				}
-				v += 0.02
-				soil.Pin.Set(v)
-			}
-		}
-	}()
+	g.stopOnce.Do(func() {
+		g.stopTickers()
+		g.stopRules()
+		g.stopDeliveries()
+		g.cancel()
+		g.runPhases(g.shutdownPhases())
+		close(g.Done)
+	})
 }
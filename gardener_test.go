@@ -0,0 +1,142 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// settledGoroutines returns the number of goroutines once those
+// already on their way out, such as the broker's, have gone.
+func settledGoroutines() int {
+	n := runtime.NumGoroutine()
+	for range 100 {
+		time.Sleep(10 * time.Millisecond)
+		m := runtime.NumGoroutine()
+		if m == n {
+			return n
+		}
+		n = m
+	}
+	return n
+}
+
+func TestStopLeavesNoGoroutines(t *testing.T) {
+	g, _ := testGardener(t)
+	before := settledGoroutines()
+	if err := g.Init(); err != nil {
+		t.Fatal(err)
+	}
+	g.Start()
+	time.Sleep(100 * time.Millisecond)
+	g.Stop()
+
+	// Goroutines take a moment to return once cancelled.
+	deadline := time.Now().Add(2 * time.Second)
+	after := runtime.NumGoroutine()
+	for after > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		after = runtime.NumGoroutine()
+	}
+	if after > before {
+		buf := make([]byte, 1<<20)
+		t.Errorf("%d goroutines before Init, %d after Stop:\n%s", before, after, buf[:runtime.Stack(buf, true)])
+	}
+}
+
+func TestStopTwice(t *testing.T) {
+	g, _ := testGardener(t)
+	if err := g.Init(); err != nil {
+		t.Fatal(err)
+	}
+	g.Start()
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		var wg sync.WaitGroup
+		for range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				g.Stop()
+			}()
+		}
+		wg.Wait()
+		g.Stop()
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Stop blocked")
+	}
+
+	select {
+	case <-g.Done:
+	default:
+		t.Error("Done not closed after Stop")
+	}
+	if g.ctx.Err() == nil {
+		t.Error("context not cancelled after Stop")
+	}
+}
+
+// namedDevice is a device that is only a name.
+type namedDevice string
+
+func (d namedDevice) Name() string { return string(d) }
+
+func TestAddDeviceDuplicateName(t *testing.T) {
+	g := &Gardener{}
+	g.addDevice(namedDevice("soil"))
+	g.addDevice(namedDevice("pump"))
+	g.addDevice(namedDevice("soil"))
+	if len(g.initErrs) != 1 || !strings.Contains(g.initErrs[0].Error(), `duplicate device name "soil"`) {
+		t.Errorf("errors %v, want one for the duplicate soil", g.initErrs)
+	}
+}
+
+func TestInitRejectsDuplicateDeviceName(t *testing.T) {
+	g, _ := testGardener(t)
+	config.EnvSensors = stringList{"soil=0x76"}
+	err := g.Init()
+	t.Cleanup(g.Stop)
+	if err == nil || !strings.Contains(err.Error(), `duplicate device name "soil"`) {
+		t.Errorf("Init error %v, want the duplicate soil named", err)
+	}
+}
+
+// TestNoPublishAfterStop records what a fast sensor ticker publishes
+// and checks nothing is published once Stop has returned.
+func TestNoPublishAfterStop(t *testing.T) {
+	g, b := testGardener(t)
+	if err := g.Init(); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	stopped := false
+	var late int
+	g.startTicker("tick", time.Millisecond, func(time.Time) {
+		g.publish("d/tick", []byte("tick"))
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped {
+			late++
+		}
+	})
+	g.Start()
+	b.waitFor(t, "d/tick", "tick")
+	g.Stop()
+	mu.Lock()
+	stopped = true
+	mu.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if late > 0 {
+		t.Errorf("%d publishes after Stop returned", late)
+	}
+}
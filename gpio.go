@@ -0,0 +1,75 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/rustyeddy/devices"
+	"github.com/rustyeddy/devices/button"
+)
+
+// levelReader is an input whose level can be read directly, which is
+// all polling needs.
+type levelReader interface {
+	Get() (int, error)
+}
+
+// pollSource turns a polled input into an eventSource, reporting a
+// rising or falling edge whenever the level read changes. It is for
+// platforms where GPIO edge interrupts are missing or unreliable.
+type pollSource struct {
+	g        *Gardener
+	name     string
+	in       levelReader
+	interval time.Duration
+}
+
+func (p *pollSource) RegisterEventHandler(h func(*devices.DeviceEvent)) {
+	ticker := time.NewTicker(p.interval)
+	p.g.goSafe(p.name+"-poll", func() {
+		last, err := p.in.Get()
+		if err != nil {
+			slog.Error("gpio poll failed", "device", p.name, "error", err)
+		}
+		for {
+			select {
+			case <-p.g.ctx.Done():
+				ticker.Stop()
+				return
+			case <-ticker.C:
+			}
+			v, err := p.in.Get()
+			if err != nil {
+				slog.Error("gpio poll failed", "device", p.name, "error", err)
+				continue
+			}
+			if v == last {
+				continue
+			}
+			last = v
+			evt := &devices.DeviceEvent{Type: devices.DeviceEventFallingEdge}
+			if v != 0 {
+				evt.Type = devices.DeviceEventRisingEdge
+			}
+			h(evt)
+		}
+	})
+}
+
+// gpioMode is the way button edges are detected, for the startup log.
+func gpioMode() string {
+	if config.GPIOPoll > 0 {
+		return "polling"
+	}
+	return "interrupt"
+}
+
+// inputSource returns the event source for a button: the button itself,
+// whose edges come from GPIO interrupts, or a poller of its level when
+// config.GPIOPoll forces polling.
+func (g *Gardener) inputSource(name string, b *button.Button) eventSource {
+	if config.GPIOPoll <= 0 {
+		return b
+	}
+	return &pollSource{g: g, name: name, in: b, interval: config.GPIOPoll}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// PCF8574 backpack bits wired to the HD44780.
+const (
+	lcdRS        = 0x01
+	lcdEnable    = 0x04
+	lcdBacklight = 0x08
+)
+
+// lcdRowAddr is the display RAM address each row starts at.
+var lcdRowAddr = []byte{0x00, 0x40, 0x14, 0x54}
+
+// HD44780 is a character LCD, like the common 16x2, driven in 4-bit
+// mode through a PCF8574 I2C backpack. Printf fills a line buffer that
+// Draw writes out.
+type HD44780 struct {
+	name       string
+	bus        string
+	cols, rows int
+	f          *os.File // nil in mock mode
+	lines      []string
+}
+
+func newHD44780(name, bus string, addr, cols, rows int) (*HD44780, error) {
+	if rows > len(lcdRowAddr) {
+		return nil, fmt.Errorf("hd44780 has at most %d rows", len(lcdRowAddr))
+	}
+	d := &HD44780{name: name, bus: bus, cols: cols, rows: rows, lines: make([]string, rows)}
+	if config.Mock {
+		return d, nil
+	}
+
+	var err error
+	if d.f, err = openI2C(bus, addr); err != nil {
+		return nil, err
+	}
+	l := busLock(bus)
+	l.Lock()
+	defer l.Unlock()
+
+	// Reset into 4-bit mode, then two lines, display on, no cursor,
+	// left to right and clear.
+	time.Sleep(50 * time.Millisecond)
+	for _, wait := range []time.Duration{5 * time.Millisecond, 5 * time.Millisecond, 150 * time.Microsecond} {
+		if err := d.nibble(0x03, 0); err != nil {
+			return nil, err
+		}
+		time.Sleep(wait)
+	}
+	if err := d.nibble(0x02, 0); err != nil {
+		return nil, err
+	}
+	for _, cmd := range []byte{0x28, 0x0c, 0x06, 0x01} {
+		if err := d.write(cmd, 0); err != nil {
+			return nil, err
+		}
+	}
+	time.Sleep(2 * time.Millisecond)
+	return d, nil
+}
+
+func (d *HD44780) Name() string {
+	return d.name
+}
+
+// nibble clocks the low four bits of n into the LCD.
+func (d *HD44780) nibble(n, rs byte) error {
+	b := n<<4 | rs | lcdBacklight
+	if _, err := d.f.Write([]byte{b | lcdEnable, b}); err != nil {
+		return err
+	}
+	time.Sleep(50 * time.Microsecond)
+	return nil
+}
+
+// write sends a command, or with rs set a character.
+func (d *HD44780) write(b, rs byte) error {
+	if err := d.nibble(b>>4, rs); err != nil {
+		return err
+	}
+	return d.nibble(b&0x0f, rs)
+}
+
+func (d *HD44780) Clear() {
+	for i := range d.lines {
+		d.lines[i] = ""
+	}
+}
+
+func (d *HD44780) Printf(line int, format string, args ...any) {
+	if line >= 0 && line < d.rows {
+		d.lines[line] = fmt.Sprintf(format, args...)
+	}
+}
+
+// Draw writes every line, cut or padded to the width of the display.
+func (d *HD44780) Draw() error {
+	if d.f == nil {
+		return nil
+	}
+	return withBus(d.bus, d.draw)
+}
+
+func (d *HD44780) draw() error {
+	for row, s := range d.lines {
+		if err := d.write(0x80|lcdRowAddr[row], 0); err != nil {
+			return err
+		}
+		s = fmt.Sprintf("%-*.*s", d.cols, d.cols, s)
+		// The character ROM is ASCII with a few extras like the degree sign.
+		s = strings.ReplaceAll(s, "°", "\xdf")
+		for i := 0; i < len(s) && i < d.cols; i++ {
+			if err := d.write(s[i], lcdRS); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
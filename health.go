@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// health backs the /livez and /readyz probes.
+type health struct {
+	connected atomic.Bool
+	heartbeat atomic.Int64 // unix nanoseconds of the last sensor tick
+}
+
+// beat records that a sensor ticker fired.
+func (g *Gardener) beat() {
+	g.health.heartbeat.Store(time.Now().UnixNano())
+}
+
+// serveLive answers the liveness probe. It fails once no sensor ticker
+// has fired for config.LivenessTimeout, which means the scheduler has
+// stalled and the process should be restarted.
+func (g *Gardener) serveLive(w http.ResponseWriter, r *http.Request) {
+	if len(g.sensors) > 0 {
+		last := g.started
+		if hb := g.health.heartbeat.Load(); hb != 0 {
+			last = time.Unix(0, hb)
+		}
+		if since := time.Since(last); since > config.LivenessTimeout {
+			http.Error(w, fmt.Sprintf("no sensor tick for %s", since.Round(time.Second)), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// serveReady answers the readiness probe. The station is ready once the
+// broker is connected and a first reading is in.
+func (g *Gardener) serveReady(w http.ResponseWriter, r *http.Request) {
+	if !g.health.connected.Load() {
+		http.Error(w, "broker not connected", http.StatusServiceUnavailable)
+		return
+	}
+	if len(g.sensors) > 0 {
+		rd := g.readings.Snapshot()
+		if rd.SoilTime.IsZero() && rd.EnvTime.IsZero() && rd.SoilTempTime.IsZero() {
+			http.Error(w, "no reading yet", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// i2cBusyRetries is how many times a transfer is tried while the
+	// bus reports EBUSY, i2cBusyBackoff doubling between tries.
+	i2cBusyRetries = 3
+	i2cBusyBackoff = 5 * time.Millisecond
+)
+
+var (
+	busMu    sync.Mutex
+	busLocks = make(map[string]*sync.Mutex)
+)
+
+// busKey reduces the ways an I2C bus is named, /dev/i2c-1, i2c-1 or
+// just 1, to one key.
+func busKey(bus string) string {
+	k := strings.TrimPrefix(strings.TrimSpace(bus), "/dev/")
+	return strings.TrimPrefix(k, "i2c-")
+}
+
+// busLock returns the mutex serializing transfers on the named I2C bus,
+// so devices sharing a bus never talk over each other.
+func busLock(bus string) *sync.Mutex {
+	key := busKey(bus)
+	busMu.Lock()
+	defer busMu.Unlock()
+	l, ok := busLocks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		busLocks[key] = l
+	}
+	return l
+}
+
+// withBus runs fn holding the bus lock, retrying with backoff while the
+// bus reports EBUSY, as it can when something outside the station is
+// using it too.
+func withBus(bus string, fn func() error) error {
+	_, err := withBusTries(bus, fn)
+	return err
+}
+
+// withBusTries is withBus, also returning how many tries it took.
+func withBusTries(bus string, fn func() error) (int, error) {
+	l := busLock(bus)
+	l.Lock()
+	defer l.Unlock()
+	var err error
+	for attempt := 0; attempt < i2cBusyRetries; attempt++ {
+		if err = fn(); !errors.Is(err, syscall.EBUSY) {
+			return attempt + 1, err
+		}
+		slog.Debug("i2c bus busy, retrying", "bus", bus, "attempt", attempt+1)
+		time.Sleep(i2cBusyBackoff << attempt)
+	}
+	return i2cBusyRetries, err
+}
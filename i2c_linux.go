@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// i2cSlave is the I2C_SLAVE ioctl from linux/i2c-dev.h.
+const i2cSlave = 0x0703
+
+// openI2C opens the I2C bus device, e.g. /dev/i2c-1, addressed to the
+// device at addr.
+func openI2C(bus string, addr int) (*os.File, error) {
+	f, err := os.OpenFile(bus, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), i2cSlave, uintptr(addr))
+	if errno != 0 {
+		f.Close()
+		return nil, errno
+	}
+	return f, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+func openI2C(bus string, addr int) (*os.File, error) {
+	return nil, errors.New("i2c is only supported on linux")
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// mockBus stands in for an I2C bus, recording when two transfers are
+// on it at once.
+type mockBus struct {
+	mu       sync.Mutex
+	active   int
+	overlaps int
+	busy     int // how many more transfers report EBUSY
+	tries    int
+}
+
+func (b *mockBus) transfer() error {
+	b.mu.Lock()
+	b.active++
+	b.tries++
+	if b.active > 1 {
+		b.overlaps++
+	}
+	busy := b.busy > 0
+	if busy {
+		b.busy--
+	}
+	b.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	b.mu.Lock()
+	b.active--
+	b.mu.Unlock()
+	if busy {
+		return syscall.EBUSY
+	}
+	return nil
+}
+
+// TestWithBusSerializes runs transfers from many goroutines, under
+// the different names of one bus, and checks none overlap.
+func TestWithBusSerializes(t *testing.T) {
+	bus := &mockBus{}
+	names := []string{"/dev/i2c-1", "i2c-1", "1"}
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if err := withBus(name, bus.transfer); err != nil {
+				t.Error(err)
+			}
+		}(names[i%len(names)])
+	}
+	wg.Wait()
+	if bus.overlaps != 0 {
+		t.Errorf("%d transfers overlapped", bus.overlaps)
+	}
+	if bus.tries != 30 {
+		t.Errorf("%d transfers, want 30", bus.tries)
+	}
+}
+
+func TestWithBusRetriesBusy(t *testing.T) {
+	tests := []struct {
+		name      string
+		busy      int
+		err       error
+		wantTries int
+		wantErr   error
+	}{
+		{"free", 0, nil, 1, nil},
+		{"busy then free", 2, nil, 3, nil},
+		{"always busy", i2cBusyRetries, nil, i2cBusyRetries, syscall.EBUSY},
+		{"other error", 0, syscall.EIO, 1, syscall.EIO},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := &mockBus{busy: tt.busy}
+			tries, err := withBusTries("test-"+tt.name, func() error {
+				if err := bus.transfer(); err != nil {
+					return err
+				}
+				return tt.err
+			})
+			if tries != tt.wantTries || bus.tries != tt.wantTries {
+				t.Errorf("tries = %d (bus saw %d), want %d", tries, bus.tries, tt.wantTries)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// influxBatchSize flushes early once this many points are waiting.
+	influxBatchSize = 500
+
+	// influxMaxPending bounds the points kept while InfluxDB is
+	// unreachable; the oldest are dropped beyond it.
+	influxMaxPending = 10000
+)
+
+// InfluxSink writes readings to InfluxDB v2 as line protocol. Points
+// are batched and flushed every config.InfluxFlush, when the batch is
+// full, and on shutdown.
+type InfluxSink struct {
+	writeURL string
+	token    string
+	client   *http.Client
+
+	mu    sync.Mutex
+	lines []string
+
+	// stored is the last point written for each measurement, for
+	// leaving out near duplicates.
+	stored map[string]storedPoint
+}
+
+type storedPoint struct {
+	fields map[string]float64
+	t      time.Time
+}
+
+func newInfluxSink(base, token, org, bucket string) *InfluxSink {
+	q := url.Values{"org": {org}, "bucket": {bucket}, "precision": {"ns"}}
+	return &InfluxSink{
+		writeURL: strings.TrimRight(base, "/") + "/api/v2/write?" + q.Encode(),
+		token:    token,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxTagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+// Point queues one point.
+func (s *InfluxSink) Point(measurement string, tags map[string]string, fields map[string]float64, t time.Time) {
+	var b strings.Builder
+	b.WriteString(influxMeasurementEscaper.Replace(measurement))
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if tags[k] == "" {
+			continue
+		}
+		fmt.Fprintf(&b, ",%s=%s", influxTagEscaper.Replace(k), influxTagEscaper.Replace(tags[k]))
+	}
+
+	keys = keys[:0]
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		sep := ","
+		if i == 0 {
+			sep = " "
+		}
+		fmt.Fprintf(&b, "%s%s=%s", sep, influxTagEscaper.Replace(k), strconv.FormatFloat(fields[k], 'f', -1, 64))
+	}
+	fmt.Fprintf(&b, " %d", t.UnixNano())
+
+	s.mu.Lock()
+	s.lines = append(s.lines, b.String())
+	if n := len(s.lines) - influxMaxPending; n > 0 {
+		s.lines = s.lines[n:]
+	}
+	full := len(s.lines) >= influxBatchSize
+	s.mu.Unlock()
+
+	if full {
+		go s.Flush()
+	}
+}
+
+// Flush writes the queued points. They are kept for the next flush if
+// the write fails.
+// worthStoring reports whether a point should be written. With
+// config.StoreDelta set, a point only is if some field moved more than
+// the delta since the last one stored or config.StoreMaxInterval has
+// passed, so flat readings still leave a keepalive point.
+func (s *InfluxSink) worthStoring(measurement string, fields map[string]float64, t time.Time) bool {
+	if config.StoreDelta <= 0 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last, ok := s.stored[measurement]
+	store := !ok || (config.StoreMaxInterval > 0 && t.Sub(last.t) >= config.StoreMaxInterval)
+	for k, v := range fields {
+		if lv, ok := last.fields[k]; !ok || math.Abs(v-lv) > config.StoreDelta {
+			store = true
+		}
+	}
+	if !store {
+		return false
+	}
+	if s.stored == nil {
+		s.stored = make(map[string]storedPoint)
+	}
+	s.stored[measurement] = storedPoint{fields: maps.Clone(fields), t: t}
+	return true
+}
+
+func (s *InfluxSink) Flush() error {
+	s.mu.Lock()
+	lines := s.lines
+	s.lines = nil
+	s.mu.Unlock()
+	if len(lines) == 0 {
+		return nil
+	}
+
+	err := s.write(lines)
+	if err != nil {
+		s.mu.Lock()
+		s.lines = append(lines, s.lines...)
+		if n := len(s.lines) - influxMaxPending; n > 0 {
+			s.lines = s.lines[n:]
+		}
+		s.mu.Unlock()
+		slog.Error("influx write failed", "points", len(lines), "error", err)
+	}
+	return err
+}
+
+func (s *InfluxSink) write(lines []string) error {
+	body := strings.Join(lines, "\n")
+	req, err := http.NewRequest(http.MethodPost, s.writeURL, bytes.NewBufferString(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+s.token)
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("influx write: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+func (g *Gardener) initInflux() {
+	if config.InfluxURL == "" {
+		return
+	}
+	g.influx = newInfluxSink(config.InfluxURL, config.InfluxToken, config.InfluxOrg, config.InfluxBucket)
+	g.every("influx", config.InfluxFlush, func(time.Time) {
+		g.influx.Flush()
+	})
+	slog.Info("influx sink enabled", "url", config.InfluxURL, "org", config.InfluxOrg, "bucket", config.InfluxBucket)
+}
+
+// writePoint records a reading in InfluxDB when the sink is enabled.
+func (g *Gardener) writePoint(measurement string, fields map[string]float64, t time.Time) {
+	if g.influx == nil || !g.influx.worthStoring(measurement, fields, t) {
+		return
+	}
+	tags := map[string]string{"station": config.StationName, "zone": config.Zone}
+	g.influx.Point(measurement, tags, fields, t)
+}
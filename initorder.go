@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// subsystem is a part of the station set up by Init, after the
+// subsystems it depends on.
+type subsystem struct {
+	name    string
+	deps    []string
+	enabled bool
+	init    func()
+}
+
+// subsystems declares the station's subsystems and what each needs set
+// up first. The declaration order breaks ties, so startup is the same
+// every time.
+func (g *Gardener) subsystems() []subsystem {
+	return []subsystem{
+		// Everything that stamps or schedules by time wants the RTC.
+		{name: "rtc", enabled: true, init: g.initRTC},
+		{name: "pump", deps: []string{"rtc"}, enabled: config.EnablePump, init: g.initPump},
+		{name: "zones", deps: []string{"pump"}, enabled: len(config.ZoneValves) > 0, init: g.initZones},
+		{name: "buttons", deps: []string{"pump"}, enabled: config.EnableButtons, init: g.initButtons},
+		{name: "env", deps: []string{"rtc"}, enabled: config.EnableEnv, init: g.initEnv},
+		{name: "display", deps: []string{"env"}, enabled: config.EnableDisplay, init: g.initDisplay},
+		{name: "led", enabled: config.LEDRed >= 0 && config.LEDGreen >= 0 && config.LEDBlue >= 0, init: g.initLED},
+		{name: "emulator", enabled: config.Mock, init: g.initEmulator},
+		{name: "soil", deps: []string{"rtc", "pump", "display", "led", "emulator"}, enabled: config.EnableSoil, init: g.InitSoil},
+		{name: "encoder", deps: []string{"display"}, enabled: config.EncoderA >= 0 && config.EncoderB >= 0, init: g.initEncoder},
+		{name: "soiltemp", deps: []string{"rtc"}, enabled: len(config.DS18B20) > 0, init: g.initSoilTempSensors},
+		{name: "rules", deps: []string{"pump", "zones", "led", "emulator"}, enabled: config.RulesFile != "", init: g.initRules},
+	}
+}
+
+// initOrder sorts the enabled subsystems so each comes after those it
+// depends on, adding the config.InitAfter dependencies. A dependency
+// on a disabled subsystem is already met.
+func initOrder(subs []subsystem) ([]subsystem, error) {
+	byName := make(map[string]int, len(subs))
+	for i, s := range subs {
+		byName[s.name] = i
+	}
+	deps := make([][]string, len(subs))
+	for i, s := range subs {
+		deps[i] = slices.Clone(s.deps)
+	}
+	for _, entry := range config.InitAfter {
+		name, dep, ok := strings.Cut(entry, "=")
+		i, known := byName[name]
+		if !ok || !known {
+			return nil, fmt.Errorf("init-after %q: expected subsystem=dependency", entry)
+		}
+		if _, known := byName[dep]; !known {
+			return nil, fmt.Errorf("init-after %q: unknown subsystem %q", entry, dep)
+		}
+		deps[i] = append(deps[i], dep)
+	}
+
+	// Disabled subsystems start out done, so they are met but left out.
+	done := make([]bool, len(subs))
+	want := 0
+	for i, s := range subs {
+		done[i] = !s.enabled
+		if s.enabled {
+			want++
+		}
+	}
+	ready := func(i int) bool {
+		for _, d := range deps[i] {
+			if !done[byName[d]] {
+				return false
+			}
+		}
+		return true
+	}
+
+	var order []subsystem
+	for len(order) < want {
+		next := -1
+		for i := range subs {
+			if !done[i] && ready(i) {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			var stuck []string
+			for i, s := range subs {
+				if !done[i] {
+					stuck = append(stuck, s.name)
+				}
+			}
+			return nil, fmt.Errorf("subsystem dependency cycle among %s", strings.Join(stuck, ", "))
+		}
+		done[next] = true
+		order = append(order, subs[next])
+	}
+	return order, nil
+}
+
+// initSubsystems sets the subsystems up in dependency order.
+func (g *Gardener) initSubsystems() {
+	order, err := initOrder(g.subsystems())
+	if err != nil {
+		panic(err)
+	}
+	names := make([]string, len(order))
+	for i, s := range order {
+		names[i] = s.name
+	}
+	slog.Info("subsystem init order", "order", strings.Join(names, ","))
+	for _, s := range order {
+		s.init()
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	gomqtt "github.com/eclipse/paho.mqtt.golang"
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/rustyeddy/otto/server"
+)
+
+// testWait bounds how long an integration test waits for a message.
+const testWait = 5 * time.Second
+
+// testBroker is an in-process MQTT broker for integration tests, with
+// a client of its own that records every message published through
+// it, so a test can check what the station sent.
+type testBroker struct {
+	addr   string
+	client gomqtt.Client
+
+	mu   sync.Mutex
+	msgs map[string][]string // payloads by topic, in order
+	seen map[string]int      // how many of them waitFor has gone past
+}
+
+// newTestBroker starts a broker that accepts every client on a free
+// local port, stopped when the test ends.
+func newTestBroker(t *testing.T) *testBroker {
+	t.Helper()
+	return startTestBroker(t, freeAddr(t))
+}
+
+// freeAddr returns a local address nothing is listening on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// startTestBroker starts a test broker on addr.
+func startTestBroker(t *testing.T, addr string) *testBroker {
+	t.Helper()
+	s := mqtt.New(nil)
+	if err := s.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddListener(listeners.NewTCP(listeners.Config{ID: "test", Address: addr})); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		if err := s.Serve(); err != nil {
+			slog.Error("test broker failed", "error", err)
+		}
+	}()
+	t.Cleanup(func() { s.Close() })
+
+	b := &testBroker{addr: addr, msgs: make(map[string][]string), seen: make(map[string]int)}
+	opts := gomqtt.NewClientOptions().AddBroker("tcp://" + addr).SetClientID("test-observer")
+	b.client = gomqtt.NewClient(opts)
+	if tok := b.client.Connect(); tok.WaitTimeout(testWait) && tok.Error() != nil {
+		t.Fatalf("test broker: %v", tok.Error())
+	}
+	t.Cleanup(func() { b.client.Disconnect(0) })
+	tok := b.client.Subscribe("#", 0, func(_ gomqtt.Client, m gomqtt.Message) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.msgs[m.Topic()] = append(b.msgs[m.Topic()], string(m.Payload()))
+	})
+	if tok.WaitTimeout(testWait) && tok.Error() != nil {
+		t.Fatalf("test broker: %v", tok.Error())
+	}
+	return b
+}
+
+// publish sends data on topic, as another station or a dashboard would.
+func (b *testBroker) publish(t *testing.T, topic, data string) {
+	t.Helper()
+	if tok := b.client.Publish(topic, 0, false, data); tok.WaitTimeout(testWait) && tok.Error() != nil {
+		t.Fatalf("publish %s: %v", topic, tok.Error())
+	}
+}
+
+// waitFor waits for data on topic after the message the last waitFor
+// for topic matched, failing the test if it does not come.
+func (b *testBroker) waitFor(t *testing.T, topic, data string) {
+	t.Helper()
+	deadline := time.Now().Add(testWait)
+	for {
+		b.mu.Lock()
+		msgs := b.msgs[topic]
+		for i := b.seen[topic]; i < len(msgs); i++ {
+			if msgs[i] == data {
+				b.seen[topic] = i + 1
+				b.mu.Unlock()
+				return
+			}
+		}
+		b.mu.Unlock()
+		if time.Now().After(deadline) {
+			t.Fatalf("no %q on %s within %s; got %q", data, topic, testWait, msgs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// received returns the payloads published on topic so far.
+func (b *testBroker) received(topic string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.msgs[topic]...)
+}
+
+// testGardener returns a -mock station wired to a test broker, ready
+// for Init, and the broker. Any config changes the test makes are
+// undone when it ends.
+func testGardener(t *testing.T) (*Gardener, *testBroker) {
+	t.Helper()
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config.Mock = true
+	config.EnableDisplay = false
+	config.DataDir = ""
+	config.ConnectRetry = 0
+	config.ShutdownTimeout = time.Second
+	config.ShutdownStepDelay = 0
+
+	b := newTestBroker(t)
+	config.Broker = b.addr
+
+	g := &Gardener{Server: server.NewServer()}
+	g.Server.Addr = "127.0.0.1:0"
+	return g, b
+}
+
+// setSoil sets the emulated soil moisture over the broker and takes
+// a soil reading at once rather than waiting for the ticker.
+func setSoil(t *testing.T, g *Gardener, b *testBroker, soil float64) {
+	t.Helper()
+	b.publish(t, "c/emulator/set", fmt.Sprintf(`{"soil": %g}`, soil))
+	deadline := time.Now().Add(testWait)
+	for v, ok := g.emu.soil(); !ok || v != soil; v, ok = g.emu.soil() {
+		if time.Now().After(deadline) {
+			t.Fatal("emulator soil not set over the broker")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	g.sensors["soil"](now())
+}
+
+// TestIntegrationWatering runs automatic watering end to end through
+// the broker: a dry reading publishes pump on, a wet one pump off.
+func TestIntegrationWatering(t *testing.T) {
+	g, b := testGardener(t)
+	config.AutoWater = true
+	config.WaterConfirm = 1
+	config.LowThreshold, config.HighThreshold = 30, 50
+	config.BootGrace = 0
+	if err := g.Init(); err != nil {
+		t.Fatal(err)
+	}
+	g.Start()
+	t.Cleanup(g.Stop)
+
+	setSoil(t, g, b, 10)
+	b.waitFor(t, "c/pump", "on")
+	setSoil(t, g, b, 90)
+	b.waitFor(t, "c/pump", "off")
+}
+
+// TestIntegrationLateBroker starts the station with the broker down:
+// it runs its local schedulers, going offline, and connects in the
+// background once the broker is up.
+func TestIntegrationLateBroker(t *testing.T) {
+	g, _ := testGardener(t)
+	config.Broker = freeAddr(t)
+	config.OfflinePolicy = offlineLocal
+	config.OfflineAfter = 100 * time.Millisecond
+	if err := g.Init(); err != nil {
+		t.Fatal(err)
+	}
+	g.Start()
+	t.Cleanup(g.Stop)
+
+	waitUntil(t, "offline mode", func() bool { return g.offlinePolicy() == offlineLocal })
+	startTestBroker(t, config.Broker)
+	waitUntil(t, "the broker connecting", g.health.connected.Load)
+	waitUntil(t, "leaving offline mode", func() bool { return g.offlinePolicy() == offlineNone })
+}
+
+// TestIntegrationFinalReading stops the station with the soil dry and
+// checks the final reading is published but does not start the pump.
+func TestIntegrationFinalReading(t *testing.T) {
+	g, b := testGardener(t)
+	config.AutoWater = true
+	config.WaterConfirm = 1
+	config.LowThreshold, config.HighThreshold = 30, 50
+	config.BootGrace = 0
+	config.PublishOnShutdown = true
+	config.SoilPublishOnChange = false
+	if err := g.Init(); err != nil {
+		t.Fatal(err)
+	}
+	g.Start()
+	t.Cleanup(g.Stop)
+
+	dry := 10.0
+	g.emu.mu.Lock()
+	g.emu.s.Soil = &dry
+	g.emu.mu.Unlock()
+	g.Stop()
+
+	decimals, _ := precision("soil")
+	b.waitFor(t, "d/soil", fmt.Sprintf("%5.*f", decimals, dry))
+	if got := b.received("c/pump"); len(got) > 0 {
+		t.Errorf("final reading sent %q on c/pump", got)
+	}
+	if g.readings.Snapshot().Pump {
+		t.Error("pump on after Stop")
+	}
+}
+
+// waitUntil waits for cond, failing the test if it is not met within
+// testWait.
+func waitUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(testWait)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("gave up waiting for %s after %s", what, testWait)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// jitter returns d changed by a random amount of up to
+// config.TickerJitter percent either way, so sensors sharing an
+// interval drift apart instead of reading the bus in lockstep.
+func jitter(d time.Duration) time.Duration {
+	if config.TickerJitter <= 0 {
+		return d
+	}
+	spread := float64(d) * config.TickerJitter / 100
+	return d + time.Duration((rand.Float64()*2-1)*spread)
+}
@@ -0,0 +1,25 @@
+package main
+
+// readLimiter is a semaphore bounding how many device reads run at
+// once. Sensors that share a bus can time out when they are read
+// together; a limit of 1 serializes them at the cost of latency. A nil
+// readLimiter places no limit.
+type readLimiter chan struct{}
+
+func newReadLimiter(n int) readLimiter {
+	if n <= 0 {
+		return nil
+	}
+	return make(readLimiter, n)
+}
+
+// do runs the read fn once a slot is free.
+func (l readLimiter) do(fn func()) {
+	if l == nil {
+		fn()
+		return
+	}
+	l <- struct{}{}
+	defer func() { <-l }()
+	fn()
+}
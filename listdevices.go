@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// Where the fixed devices live and how often they are read. Init and
+// plannedDevices both use these so -list-devices shows what runs.
+const (
+	soilInterval = 10 * time.Second
+	envInterval  = 10 * time.Second
+	envBus       = "/dev/i2c-1"
+	envAddr      = 0x76
+	displayBus   = 1
+	displayAddr  = 0x27
+	lcdCols      = 16
+	lcdRows      = 2
+)
+
+// deviceSpec describes a device the configuration creates.
+type deviceSpec struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Address  string `json:"address"`
+	Interval string `json:"interval,omitempty"`
+}
+
+func gpio(pin int) string {
+	return fmt.Sprintf("gpio%d", pin)
+}
+
+// plannedDevices returns the devices Init would create with the current
+// configuration, without touching any hardware.
+func plannedDevices() ([]deviceSpec, error) {
+	var specs []deviceSpec
+	add := func(name, typ, addr string, interval time.Duration) {
+		s := deviceSpec{Name: name, Type: typ, Address: addr}
+		if interval > 0 {
+			s.Interval = interval.String()
+		}
+		specs = append(specs, s)
+	}
+
+	if config.RTCBus != "" {
+		add("rtc", "ds3231", fmt.Sprintf("%s 0x%02x", config.RTCBus, config.RTCAddr), 0)
+	}
+	if config.EnableButtons {
+		add("on", "button", gpio(config.Pins.pin("on")), config.GPIOPoll)
+		add("off", "button", gpio(config.Pins.pin("off")), config.GPIOPoll)
+	}
+	if config.EnablePump {
+		add("pump", "relay", gpio(config.Pins.pin("pump")), 0)
+		if config.PumpFeedbackPin >= 0 {
+			add("pump-feedback", "button", gpio(config.PumpFeedbackPin), 0)
+		}
+	}
+	zones, err := zoneSpecs()
+	if err != nil {
+		return nil, err
+	}
+	for _, z := range zones {
+		add(zoneValveName(z.name), "relay", gpio(z.pin), 0)
+	}
+	if config.EnableEnv {
+		envs, err := envSensors()
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range envs {
+			add(e.name, "bme280", fmt.Sprintf("%s 0x%02x", envBus, e.addr), envInterval)
+		}
+	}
+	if config.EnableDisplay {
+		add("c/lcd", config.DisplayType, fmt.Sprintf("/dev/i2c-%d 0x%02x", displayBus, displayAddr), 0)
+	}
+	if config.EnableSoil {
+		typ := "vh400"
+		if soilMode("soil") == soilModePercent {
+			typ = "soil-percent"
+		}
+		add("soil", typ, gpio(config.Pins.pin("soil")), soilInterval)
+	}
+	if config.EncoderA >= 0 && config.EncoderB >= 0 {
+		addr := gpio(config.EncoderA) + " " + gpio(config.EncoderB)
+		if config.EncoderPush >= 0 {
+			addr += " " + gpio(config.EncoderPush)
+		}
+		add("encoder", "rotary-encoder", addr, 0)
+	}
+	if config.LEDRed >= 0 && config.LEDGreen >= 0 && config.LEDBlue >= 0 {
+		add("led", "rgb-led", gpio(config.LEDRed)+" "+gpio(config.LEDGreen)+" "+gpio(config.LEDBlue), 0)
+	}
+	for _, id := range config.DS18B20 {
+		add(id, "ds18b20", "w1 "+id, config.SoilTempInterval)
+	}
+	return specs, nil
+}
+
+// listDevices writes the planned devices to w as a table, or as JSON.
+func listDevices(w io.Writer, asJSON bool) error {
+	specs, err := plannedDevices()
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(specs)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tADDRESS\tINTERVAL")
+	for _, s := range specs {
+		interval := s.Interval
+		if interval == "" {
+			interval = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Name, s.Type, s.Address, interval)
+	}
+	return tw.Flush()
+}
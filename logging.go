@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/rustyeddy/otto/utils"
+)
+
+// logSink is one -log-output entry: an output and optionally the
+// format to write to it, e.g. "stdout:text" or "file:json".
+type logSink struct {
+	Output string
+	Format string
+}
+
+// logSinks is the -log-output flag, a comma separated list of sinks
+// that are all written at once.
+type logSinks []logSink
+
+func (l *logSinks) String() string {
+	if l == nil {
+		return ""
+	}
+	var parts []string
+	for _, s := range *l {
+		if s.Format == "" {
+			parts = append(parts, s.Output)
+		} else {
+			parts = append(parts, s.Output+":"+s.Format)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *logSinks) Set(v string) error {
+	var sinks logSinks
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		out, format, _ := strings.Cut(part, ":")
+		switch out {
+		case "stdout", "stderr", "file":
+		default:
+			return fmt.Errorf("unknown log output %q", out)
+		}
+		switch format {
+		case "", "text", "json":
+		default:
+			return fmt.Errorf("unknown log format %q", format)
+		}
+		sinks = append(sinks, logSink{Output: out, Format: format})
+	}
+	*l = sinks
+	return nil
+}
+
+// initLogging sets up the default logger and tags it with the station
+// name and any -log-attr fields.
+func initLogging() error {
+	attrs, err := logAttrs()
+	if err != nil {
+		return err
+	}
+	if err := initLogHandlers(); err != nil {
+		return err
+	}
+	slog.SetDefault(slog.Default().With(attrs...))
+	return nil
+}
+
+// initLoggingFallback is initLogging that, unless config.StrictLogging,
+// survives a log file that cannot be written: it warns on stderr and
+// logs there in place of the file, as a station is better running with
+// its logs in the wrong place than not running at all.
+func initLoggingFallback() error {
+	err := initLogging()
+	if err == nil || config.StrictLogging || !config.LogSinks.hasFile() {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "WARNING: cannot log to %s: %v; logging to stderr instead\n", config.Log.FilePath, err)
+	config.LogSinks = config.LogSinks.withoutFile()
+	if ferr := initLogging(); ferr != nil {
+		return errors.Join(err, ferr)
+	}
+	slog.Warn("log file unusable, logging to stderr", "file", config.Log.FilePath, "error", err)
+	return nil
+}
+
+func (l logSinks) hasFile() bool {
+	if len(l) == 0 {
+		return config.Log.Output.String() == "file"
+	}
+	for _, s := range l {
+		if s.Output == "file" {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutFile returns the sinks with the file output moved to stderr.
+func (l logSinks) withoutFile() logSinks {
+	if len(l) == 0 {
+		return logSinks{{Output: "stderr", Format: config.Log.Format.String()}}
+	}
+	var sinks logSinks
+	for _, s := range l {
+		if s.Output == "file" {
+			s.Output = "stderr"
+		}
+		if !slices.Contains(sinks, s) {
+			sinks = append(sinks, s)
+		}
+	}
+	return sinks
+}
+
+// logAttrs returns the static attributes attached to every log line.
+func logAttrs() ([]any, error) {
+	attrs := []any{"station", config.StationName}
+	for _, kv := range config.LogAttrs {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("log attribute %q: expected key=value", kv)
+		}
+		attrs = append(attrs, k, v)
+	}
+	return attrs, nil
+}
+
+// logFilePath returns the log file to write: path when one is given,
+// otherwise one named after the station, so several stations on one
+// host keep their own logs.
+func logFilePath(path, station string) string {
+	if path != "" {
+		return path
+	}
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || unicode.IsSpace(r) {
+			return '-'
+		}
+		return r
+	}, station)
+	if name == "" || name == "." || name == ".." {
+		name = "gardener"
+	}
+	return name + ".log"
+}
+
+// initLogHandlers installs the default handler. A single sink is
+// handed to utils.InitLogger as before; several sinks each get their
+// own handler.
+func initLogHandlers() error {
+	sinks := config.LogSinks
+	if len(sinks) <= 1 {
+		if len(sinks) == 1 {
+			config.Log.Output.Set(sinks[0].Output)
+			if sinks[0].Format != "" {
+				config.Log.Format.Set(sinks[0].Format)
+			}
+		}
+		config.LogSinks = logSinks{{Output: config.Log.Output.String(), Format: config.Log.Format.String()}}
+		_, err := utils.InitLogger(config.Log)
+		return err
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.Log.Level)); err != nil {
+		return fmt.Errorf("log level %q: %w", config.Log.Level, err)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handlers multiHandler
+	for _, s := range sinks {
+		var w io.Writer
+		switch s.Output {
+		case "stdout":
+			w = os.Stdout
+		case "stderr":
+			w = os.Stderr
+		case "file":
+			f, err := os.OpenFile(config.Log.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				return err
+			}
+			w = f
+		}
+
+		format := s.Format
+		if format == "" {
+			format = config.Log.Format.String()
+		}
+		if format == "json" {
+			handlers = append(handlers, slog.NewJSONHandler(w, opts))
+		} else {
+			handlers = append(handlers, slog.NewTextHandler(w, opts))
+		}
+	}
+	slog.SetDefault(slog.New(handlers))
+	return nil
+}
+
+// multiHandler sends every record to each of its handlers.
+type multiHandler []slog.Handler
+
+func (m multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range m {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range m {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	hs := make(multiHandler, len(m))
+	for i, h := range m {
+		hs[i] = h.WithAttrs(attrs)
+	}
+	return hs
+}
+
+func (m multiHandler) WithGroup(name string) slog.Handler {
+	hs := make(multiHandler, len(m))
+	for i, h := range m {
+		hs[i] = h.WithGroup(name)
+	}
+	return hs
+}
+
+// changeLog picks the level of a repeated reading log: info when any
+// value moved more than config.LogDelta since it was last logged at
+// info, debug otherwise, so a steady station does not flood the log.
+type changeLog struct {
+	mu   sync.Mutex
+	last map[string]float64
+}
+
+func (c *changeLog) level(values map[string]float64) slog.Level {
+	if config.LogDelta <= 0 {
+		return slog.LevelInfo
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	changed := c.last == nil
+	for k, v := range values {
+		if last, ok := c.last[k]; !ok || math.Abs(v-last) > config.LogDelta {
+			changed = true
+		}
+	}
+	if !changed {
+		return slog.LevelDebug
+	}
+	c.last = maps.Clone(values)
+	return slog.LevelInfo
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/rustyeddy/devices"
 	"github.com/rustyeddy/otto/utils"
@@ -14,12 +15,376 @@ import (
 
 type Config struct {
 	StationName string
-	Mock        bool
-	Log         utils.LogConfig
+	Zone        string
+	Timezone    string
+
+	// ConfigFile and ConfigURL are YAML files of option values, below
+	// the command line and environment in precedence. The last good
+	// ConfigURL is kept in ConfigCache, by default in DataDir.
+	ConfigFile  string
+	ConfigURL   string
+	ConfigCache string
+
+	// DataDir is where the station keeps files across restarts, empty
+	// to keep nothing.
+	DataDir string
+
+	// SummaryRetentionDays, when set, deletes daily summaries in
+	// DataDir older than this many days.
+	SummaryRetentionDays int
+
+	Mock     bool
+	Log      utils.LogConfig
+	LogSinks logSinks
+	LogAttrs stringList
+
+	// StrictLogging exits when the log file cannot be written rather
+	// than falling back to stderr.
+	StrictLogging bool
+
+	// LogDelta logs a reading at info only when it moved more than this
+	// since last logged at info, and at debug otherwise.
+	LogDelta float64
 
 	Broker   string
 	Username string
 	Password string
+
+	// ConnectRetry is how long an unreachable broker is retried at
+	// startup. Rejected credentials are never retried.
+	ConnectRetry time.Duration
+
+	// MQTTKeepAlive, MQTTConnectTimeout and MQTTPingTimeout tune the
+	// MQTT client, zero keeping its defaults.
+	MQTTKeepAlive      time.Duration
+	MQTTConnectTimeout time.Duration
+	MQTTPingTimeout    time.Duration
+
+	// DeliveryTimeout is how long a pump command is given to come back
+	// from the broker before it is published again, up to
+	// DeliveryRetries more times. 0 disables confirmation.
+	DeliveryTimeout time.Duration
+	DeliveryRetries int
+
+	// OfflinePolicy is what the station does once the broker has been
+	// unreachable for OfflineAfter: none, local-autonomous or
+	// conservative.
+	OfflinePolicy string
+	OfflineAfter  time.Duration
+
+	// Subsystems can be switched off for incremental hardware bring-up.
+	EnableSoil    bool
+	EnableEnv     bool
+	EnableButtons bool
+	EnableDisplay bool
+	EnablePump    bool
+
+	// Pins are the GPIO pins of the buttons, soil sensor and pump by
+	// name, defaulting to those of the reference board.
+	Pins pinMap
+
+	// PumpFeedbackPin is an optional current-sense or flow input used
+	// to confirm the pump started, -1 to disable.
+	PumpFeedbackPin     int
+	PumpFeedbackTimeout time.Duration
+
+	// PumpMaxRunSeconds caps how long the pump may run in one watering,
+	// including every cycle of a soak.
+	PumpMaxRunSeconds int
+
+	// EfficiencyWindow is how long after a watering the soil is watched
+	// for its response, 0 to disable. PumpFlowRate, in liters a minute,
+	// lets the response be given per liter.
+	EfficiencyWindow time.Duration
+	PumpFlowRate     float64
+
+	// PumpMinRuntime is the least time the pump runs once started;
+	// earlier offs are deferred to protect the motor.
+	PumpMinRuntime time.Duration
+
+	// PumpExercise, when set, runs the pump for PumpExerciseRun if it
+	// has sat idle this long, to keep it from seizing.
+	PumpExercise    time.Duration
+	PumpExerciseRun time.Duration
+
+	// PumpTestDuration is how long an installer's test run lasts when
+	// none is asked for, never more than pumpTestMax.
+	PumpTestDuration time.Duration
+
+	// SoakCycles greater than one makes every pump "on" a soak sequence
+	// of SoakCycles x (SoakOn on, SoakOff off).
+	SoakCycles int
+	SoakOn     time.Duration
+	SoakOff    time.Duration
+
+	// DeepWaterEvery, when set, runs a deep soak of DeepWaterDuration
+	// at DeepWaterAt this often, apart from the threshold top-ups,
+	// which are then held off for DeepWaterCooldown.
+	DeepWaterEvery    time.Duration
+	DeepWaterAt       string
+	DeepWaterDuration time.Duration
+	DeepWaterCooldown time.Duration
+
+	// Readings taken during a sensor's warm-up are discarded.
+	SoilWarmup time.Duration
+	EnvWarmup  time.Duration
+
+	// RTCBus is the I2C bus of an optional DS3231 real-time clock.
+	RTCBus  string
+	RTCAddr int
+
+	// PublishTopics publishes each reading on its own topic, which Home
+	// Assistant expects. PublishState publishes all of them at once on
+	// d/state every StateInterval. Both may be enabled.
+	PublishTopics bool
+	PublishState  bool
+	StateInterval time.Duration
+
+	// GapMarker is published to every data topic on connect so charts
+	// show the outage: null, nan, object, or empty for none.
+	GapMarker string
+
+	// AutoWater turns the pump on when soil moisture drops below
+	// LowThreshold, or the ThresholdSchedule value for the time of day,
+	// and off once it reaches HighThreshold.
+	AutoWater         bool
+	LowThreshold      float64
+	HighThreshold     float64
+	ThresholdSchedule ThresholdSchedule
+
+	// WaterConfirm is how many soil readings in a row must be below
+	// the low threshold to start watering, and MaxWaterSeconds, when
+	// set, stops any automatic watering after that long.
+	WaterConfirm    int
+	MaxWaterSeconds int
+
+	// SoilDeadband is how far moisture must move from the last value
+	// acted on before automation sees a change, and with
+	// SoilPublishOnChange before d/soil is published again.
+	SoilDeadband        float64
+	SoilPublishOnChange bool
+
+	// Hysteresis, when set, stops watering this far above the low
+	// threshold rather than at the high threshold.
+	Hysteresis Hysteresis
+
+	// Profile is the watering profile to start with, by default the
+	// one saved in DataDir or normal. ProfilesFile adds profiles to the
+	// built-in normal and vacation ones.
+	Profile      string
+	ProfilesFile string
+
+	// BootGrace holds off automatic watering for this long after
+	// startup, while sensors settle. Manual control still works.
+	BootGrace time.Duration
+
+	// ManualOverride is how long a manual off keeps automatic
+	// watering away.
+	ManualOverride time.Duration
+
+	// ET0Topic and RainTopic are an external weather feed of reference
+	// evapotranspiration in mm a day and rainfall in mm. While younger
+	// than WeatherMaxAge, ET0 moves the low threshold by ET0Gain points
+	// per mm a day away from ET0Ref, and RainSkip mm of rain or more
+	// skips starting a watering.
+	ET0Topic      string
+	RainTopic     string
+	WeatherMaxAge time.Duration
+	ET0Ref        float64
+	ET0Gain       float64
+	RainSkip      float64
+
+	// EnvSensors are name=address entries, one BME280 each on its own
+	// d/env/<name> topic. Without any there is one on d/env.
+	EnvSensors stringList
+
+	// SoilModes are name=mode entries choosing how each soil sensor's
+	// reading is converted: "vh400" through its curve, the default, or
+	// "percent" for sensors that put out a percentage.
+	SoilModes stringList
+
+	// InitAfter are subsystem=dependency entries adding to the
+	// dependencies that order startup.
+	InitAfter stringList
+
+	// ZoneValves are name=pin entries, one valve relay per zone sharing
+	// the pump. ZoneBusy says what happens to a zone that wants water
+	// while another holds the pump: "queue" or "skip".
+	ZoneValves stringList
+	ZoneBusy   string
+
+	// SoilTempCoeff is the moisture correction per degree C the soil is
+	// below SoilTempRef, 0 to disable. The soil temperature is taken
+	// from SoilTempTopic or the first DS18B20.
+	SoilTempCoeff float64
+	SoilTempRef   float64
+	SoilTempTopic string
+
+	// DS18B20 lists the ROM IDs of 1-Wire soil temperature probes.
+	DS18B20          stringList
+	SoilTempInterval time.Duration
+
+	// Debounce coalesces edge events from buttons and switches arriving
+	// within the window, DebounceDevices overriding it per device.
+	Debounce        time.Duration
+	DebounceDevices durationMap
+
+	// ButtonCoalesce collapses on and off presses within the window to
+	// the last, so a fat-fingered on then off never clicks the relay.
+	ButtonCoalesce time.Duration
+
+	// GPIOPoll, when set, polls the buttons at this interval instead of
+	// relying on GPIO edge interrupts.
+	GPIOPoll time.Duration
+
+	// EncoderA and EncoderB are the quadrature pins of an optional
+	// rotary encoder that adjusts the watering thresholds by EncoderStep
+	// per detent, and EncoderPush its switch. -1 disables.
+	EncoderA    int
+	EncoderB    int
+	EncoderPush int
+	EncoderStep float64
+
+	// InfluxURL enables writing readings to InfluxDB v2, flushed every
+	// InfluxFlush.
+	InfluxURL    string
+	InfluxToken  string
+	InfluxOrg    string
+	InfluxBucket string
+	InfluxFlush  time.Duration
+
+	// MetricsPush, a Prometheus pushgateway URL, pushes the /metrics
+	// every MetricsPushInterval, for stations that cannot be scraped.
+	MetricsPush         string
+	MetricsPushInterval time.Duration
+
+	// StoreDelta, when set, leaves out stored points whose fields all
+	// moved by no more than it, writing one at least every
+	// StoreMaxInterval regardless.
+	StoreDelta       float64
+	StoreMaxInterval time.Duration
+
+	// Bounds overrides the plausible range of readings by name; those
+	// outside are discarded, or replaced by the last valid value with
+	// HoldLastValid.
+	Bounds        boundsMap
+	HoldLastValid bool
+
+	// Precision overrides the decimals each reading is rounded to
+	// before it is published, served or stored.
+	Precision precisionMap
+
+	// Calibrate corrects readings by device, or env sensor field, with
+	// value = raw*scale + offset, after the device's own conversion.
+	Calibrate calibrations
+
+	// TickerJitter randomly varies each sensor's read interval by up
+	// to this percentage so reads spread out.
+	TickerJitter float64
+
+	// MaxConcurrentReads limits how many device reads run at once,
+	// 0 for no limit.
+	MaxConcurrentReads int
+
+	// PublishOnShutdown takes a final reading from every sensor and
+	// publishes the offline status before exiting. ShutdownTimeout
+	// bounds each phase of shutdown.
+	PublishOnShutdown bool
+	ShutdownTimeout   time.Duration
+
+	// ShutdownOrder is the order actuators are switched off in on
+	// shutdown, ShutdownStepDelay apart. Unlisted ones follow, pump
+	// first.
+	ShutdownOrder     stringList
+	ShutdownStepDelay time.Duration
+
+	// LEDRed, LEDGreen and LEDBlue are the pins of an RGB LED showing
+	// moisture: red below LEDDry, green from LEDMoist, yellow between.
+	LEDRed   int
+	LEDGreen int
+	LEDBlue  int
+	LEDDry   float64
+	LEDMoist float64
+
+	// DisplayType is the display fitted: oled, or hd44780 for a 16x2
+	// character LCD on a PCF8574 I2C backpack.
+	DisplayType string
+
+	// DisplayLabels replaces the strings shown on the display, and
+	// TempUnit, C or F, is the unit temperatures are shown in.
+	DisplayLabels stringMap
+	TempUnit      string
+
+	// DisplayPages is a YAML file of the lines the display shows, a
+	// page of them every DisplayRotate, in place of the default pages.
+	DisplayPages  string
+	DisplayRotate time.Duration
+
+	// ReadingTimestamps adds a "time" field, when the sample was read,
+	// to the JSON readings.
+	ReadingTimestamps bool
+
+	// ReadingQuality adds a "quality" field to the JSON readings: good,
+	// retried, held or estimated.
+	ReadingQuality bool
+
+	// CompressOver gzips payloads larger than this many bytes and
+	// publishes them on the topic with /gz appended, 0 to never.
+	CompressOver int
+
+	// PublishQueue is how many sensor messages may wait for a slow
+	// broker before the oldest are dropped, 0 to publish synchronously.
+	PublishQueue int
+
+	// Quantiles are estimated for every sensor in the daily summary,
+	// and published on d/summary/quantiles every QuantileInterval.
+	Quantiles        quantileList
+	QuantileInterval time.Duration
+
+	// StateGetInterval is the least time between state dumps asked
+	// for on c/state/get.
+	StateGetInterval time.Duration
+
+	// DeviceTopics and DeviceCommands give devices their own state and
+	// command topics in place of the d/ and c/ defaults.
+	DeviceTopics   stringMap
+	DeviceCommands stringMap
+
+	// TopicAliases publishes readings on legacy topic names as well,
+	// while consumers migrate.
+	TopicAliases aliasMap
+
+	// RulesFile is a YAML file of automation rules, evaluated against
+	// the latest readings every RulesInterval.
+	RulesFile     string
+	RulesInterval time.Duration
+
+	// BridgeFile is a YAML file of other stations' topics to republish,
+	// transformed, under this station's topics.
+	BridgeFile string
+
+	// PressureTrendWindow is the period the pressure tendency on
+	// d/pressure/trend covers; a change of PressureTrendThreshold hPa
+	// or more over it counts as rising or falling.
+	PressureTrendWindow    time.Duration
+	PressureTrendThreshold float64
+
+	// LivenessTimeout is how long /livez tolerates no sensor ticker
+	// firing before reporting the station stalled.
+	LivenessTimeout time.Duration
+
+	// HTTPBindRetry is how long binding the HTTP port is retried at
+	// startup before running without the server.
+	HTTPBindRetry time.Duration
+
+	// ListDevices prints the devices the configuration would create, as
+	// JSON with JSON, and exits.
+	ListDevices bool
+	JSON        bool
+
+	// RecoverPanics logs, alerts and restarts after a panic in a
+	// goroutine instead of crashing. Turn it off in development.
+	RecoverPanics bool
 }
 
 var (
@@ -27,36 +392,203 @@ var (
 )
 
 func init() {
-	flag.BoolVar(&config.Mock, "mock", false, "mock gpio")
-	flag.StringVar(&config.Broker, "mqtt-broker", "otto", "MQTT broker address")
-	flag.StringVar(&config.Username, "mqtt-username", "", "MQTT broker address")
-	flag.StringVar(&config.Password, "mqtt-password", "", "MQTT broker address")
-	flag.StringVar(&config.StationName, "station-name", "gardener", "station name")
+	defineFlags(flag.CommandLine, &config)
+}
+
+// defineFlags defines every option on fs, bound to c and set to its
+// default.
+func defineFlags(fs *flag.FlagSet, c *Config) {
+	fs.BoolVar(&c.Mock, "mock", false, "mock gpio")
+	fs.StringVar(&c.Broker, "mqtt-broker", "otto", "MQTT broker address")
+	fs.DurationVar(&c.ConnectRetry, "mqtt-connect-retry", time.Minute, "how long to retry an unreachable broker at startup")
+	fs.DurationVar(&c.MQTTKeepAlive, "mqtt-keepalive", 0, "MQTT keepalive interval, 0 for the client default")
+	fs.DurationVar(&c.MQTTConnectTimeout, "mqtt-connect-timeout", 0, "MQTT connect timeout, 0 for the client default")
+	fs.DurationVar(&c.MQTTPingTimeout, "mqtt-ping-timeout", 0, "MQTT keepalive ping timeout, 0 for the client default")
+	fs.DurationVar(&c.DeliveryTimeout, "delivery-timeout", 5*time.Second, "time for a pump command to be confirmed by the broker, 0 to not confirm")
+	fs.IntVar(&c.DeliveryRetries, "delivery-retries", 2, "times an unconfirmed pump command is published again")
+	fs.StringVar(&c.OfflinePolicy, "offline-policy", offlineNone, "what to do while the broker is offline: none, local-autonomous or conservative")
+	fs.DurationVar(&c.OfflineAfter, "offline-after", 10*time.Minute, "how long the broker must be unreachable before the offline policy applies")
+	fs.StringVar(&c.Username, "mqtt-username", "", "MQTT broker username")
+	fs.StringVar(&c.Password, "mqtt-password", "", "MQTT broker password, best set with GARDENER_MQTT_PASSWORD")
+	fs.StringVar(&c.StationName, "station-name", "gardener", "station name")
+	fs.StringVar(&c.Zone, "zone", "", "zone the station waters, used to tag stored readings")
+	fs.StringVar(&c.Timezone, "timezone", "Local", "station time zone for schedules and summaries, e.g. America/Los_Angeles")
+	fs.StringVar(&c.ConfigFile, "config", "", "YAML file of option values")
+	fs.StringVar(&c.ConfigURL, "config-url", "", "URL of a YAML file of option values, fetched at boot")
+	fs.StringVar(&c.ConfigCache, "config-cache", "", "where the last good -config-url is kept, default config-cache.yaml in -data-dir")
+	fs.StringVar(&c.DataDir, "data-dir", "", "directory for files kept across restarts")
+	fs.IntVar(&c.SummaryRetentionDays, "summary-retention-days", 0, "delete daily summaries in -data-dir older than this many days, 0 to keep them all")
+	fs.BoolVar(&c.EnableSoil, "enable-soil", true, "enable the soil moisture sensor")
+	fs.BoolVar(&c.EnableEnv, "enable-env", true, "enable the env sensor")
+	fs.BoolVar(&c.EnableButtons, "enable-buttons", true, "enable the on/off buttons")
+	fs.BoolVar(&c.EnableDisplay, "enable-display", true, "enable the display")
+	fs.BoolVar(&c.EnablePump, "enable-pump", true, "enable the pump relay")
+	c.Pins = defaultPins()
+	fs.Var(&c.Pins, "pin", "GPIO pin as name=pin over the defaults, e.g. pump=23,soil=24")
+	fs.IntVar(&c.PumpFeedbackPin, "pump-feedback-pin", -1, "pump current/flow feedback pin, -1 to disable")
+	fs.DurationVar(&c.PumpFeedbackTimeout, "pump-feedback-timeout", 5*time.Second, "time allowed for pump feedback after pump on")
+	fs.IntVar(&c.PumpMaxRunSeconds, "pump-max-run", 120, "maximum pump runtime in seconds for one watering")
+	fs.DurationVar(&c.EfficiencyWindow, "efficiency-window", 15*time.Minute, "how long after a watering to watch the soil for its response, 0 to disable")
+	fs.Float64Var(&c.PumpFlowRate, "pump-flow-rate", 0, "pump flow in liters per minute, for the watering response per liter")
+	fs.DurationVar(&c.PumpExercise, "pump-exercise", 0, "run the pump briefly after it has sat idle this long, e.g. 168h, 0 to disable")
+	fs.DurationVar(&c.PumpExerciseRun, "pump-exercise-run", 2*time.Second, "how long a pump maintenance run lasts")
+	fs.DurationVar(&c.PumpTestDuration, "pump-test-duration", 3*time.Second, "default length of a pump test run, capped at 10s")
+	fs.DurationVar(&c.PumpMinRuntime, "pump-min-runtime", 0, "minimum time the pump runs once started")
+	fs.IntVar(&c.SoakCycles, "soak-cycles", 0, "water in this many pulsed soak cycles, 0 or 1 for continuous")
+	fs.DurationVar(&c.SoakOn, "soak-on", 30*time.Second, "pump on time of each soak cycle")
+	fs.DurationVar(&c.SoakOff, "soak-off", 2*time.Minute, "soak time between cycles")
+	fs.DurationVar(&c.DeepWaterEvery, "deep-water-every", 0, "run a deep soak this often, e.g. 72h, 0 to disable")
+	fs.StringVar(&c.DeepWaterAt, "deep-water-at", "05:00", "time of day of the deep soak, HH:MM")
+	fs.DurationVar(&c.DeepWaterDuration, "deep-water-duration", 10*time.Minute, "how long the pump runs for a deep soak")
+	fs.DurationVar(&c.DeepWaterCooldown, "deep-water-cooldown", 24*time.Hour, "hold off top-ups this long after a deep soak")
+	fs.DurationVar(&c.SoilWarmup, "soil-warmup", 0, "discard soil readings for this long after startup")
+	fs.DurationVar(&c.EnvWarmup, "env-warmup", 0, "discard env readings for this long after startup")
+	fs.StringVar(&c.RTCBus, "rtc-bus", "", "I2C bus of a DS3231 real-time clock, e.g. /dev/i2c-1")
+	fs.IntVar(&c.RTCAddr, "rtc-addr", 0x68, "I2C address of the DS3231 real-time clock")
+	fs.BoolVar(&c.PublishTopics, "publish-topics", true, "publish each reading on its own topic")
+	fs.BoolVar(&c.PublishState, "publish-state", false, "publish all readings together on d/state")
+	fs.DurationVar(&c.StateInterval, "state-interval", 10*time.Second, "interval between d/state publishes")
+	fs.StringVar(&c.GapMarker, "gap-marker", "", "publish a data gap marker on connect: null, nan or object")
+	fs.BoolVar(&c.AutoWater, "auto-water", false, "water automatically from soil moisture")
+	fs.Float64Var(&c.LowThreshold, "low-threshold", 30, "soil moisture below which watering starts")
+	fs.Float64Var(&c.HighThreshold, "high-threshold", 50, "soil moisture at which watering stops")
+	fs.IntVar(&c.WaterConfirm, "water-confirm", 2, "soil readings in a row below the low threshold needed to start watering")
+	fs.IntVar(&c.MaxWaterSeconds, "max-water", 0, "longest automatic watering in seconds, 0 for no limit")
+	fs.Var(&c.ThresholdSchedule, "threshold-schedule", "low threshold by time of day, e.g. 11:00-16:00=20,22:00-05:00=25")
+	fs.Float64Var(&c.SoilDeadband, "soil-deadband", 0, "moisture change needed before watering reacts, 0 to react to every reading")
+	fs.BoolVar(&c.SoilPublishOnChange, "soil-publish-on-change", false, "publish d/soil only when moisture moves outside the deadband")
+	fs.Var(&c.Hysteresis, "hysteresis", "stop watering this far above the low threshold, absolute e.g. 5 or relative e.g. 10%")
+	fs.StringVar(&c.Profile, "profile", "", "watering profile to start with, e.g. vacation, default the saved one or normal")
+	fs.StringVar(&c.ProfilesFile, "profiles", "", "YAML file of extra watering profiles")
+	fs.DurationVar(&c.BootGrace, "boot-grace", 0, "how long after startup automatic watering is suppressed")
+	fs.DurationVar(&c.ManualOverride, "manual-override", 30*time.Minute, "how long a manual off suspends automatic watering")
+	fs.StringVar(&c.ET0Topic, "et0-topic", "", "MQTT topic of an external ET0 feed in mm/day")
+	fs.StringVar(&c.RainTopic, "rain-topic", "", "MQTT topic of an external rainfall feed in mm")
+	fs.DurationVar(&c.WeatherMaxAge, "weather-max-age", 12*time.Hour, "weather feed values older than this are ignored")
+	fs.Float64Var(&c.ET0Ref, "et0-ref", 4, "ET0 in mm/day at which the low threshold is unchanged")
+	fs.Float64Var(&c.ET0Gain, "et0-gain", 2, "low threshold points added per mm/day of ET0 above -et0-ref")
+	fs.Float64Var(&c.RainSkip, "rain-skip", 5, "rainfall in mm that skips starting a watering, 0 to disable")
+	fs.Var(&c.EnvSensors, "env-sensor", "BME280 sensors as name=address, e.g. indoor=0x76,outdoor=0x77")
+	fs.Var(&c.SoilModes, "soil-mode", "soil sensor conversion as name=mode, vh400 or percent, e.g. soil=percent")
+	fs.Var(&c.InitAfter, "init-after", "extra startup dependencies as subsystem=dependency, e.g. display=soil")
+	fs.Var(&c.ZoneValves, "zone-valve", "zones sharing the pump as name=pin valve relays, e.g. beds=17,lawn=27")
+	fs.StringVar(&c.ZoneBusy, "zone-busy", "queue", "when another zone holds the shared pump: queue or skip")
+	fs.Float64Var(&c.SoilTempCoeff, "soil-temp-coeff", 0, "soil moisture temperature compensation per degree C, 0 to disable")
+	fs.Float64Var(&c.SoilTempRef, "soil-temp-ref", 20, "soil temperature in C at which no compensation is applied")
+	fs.StringVar(&c.SoilTempTopic, "soil-temp-topic", "", "topic providing soil temperature in C for compensation")
+	fs.Var(&c.DS18B20, "ds18b20", "1-wire DS18B20 ROM IDs, e.g. 28-0316a2793cff")
+	fs.DurationVar(&c.SoilTempInterval, "soil-temp-interval", 30*time.Second, "interval between DS18B20 reads")
+	fs.DurationVar(&c.Debounce, "debounce", 0, "window coalescing button and switch events")
+	fs.Var(&c.DebounceDevices, "debounce-device", "per device debounce windows, e.g. on=100ms,off=100ms")
+	fs.DurationVar(&c.ButtonCoalesce, "button-coalesce", 150*time.Millisecond, "window collapsing on and off button presses to the last one (0 to act on each press)")
+	fs.DurationVar(&c.GPIOPoll, "gpio-poll", 0, "poll buttons at this interval instead of using edge interrupts, 0 for interrupts")
+	fs.IntVar(&c.EncoderA, "encoder-a", -1, "rotary encoder A pin, -1 to disable")
+	fs.IntVar(&c.EncoderB, "encoder-b", -1, "rotary encoder B pin, -1 to disable")
+	fs.IntVar(&c.EncoderPush, "encoder-push", -1, "rotary encoder push switch pin, -1 to disable")
+	fs.Float64Var(&c.EncoderStep, "encoder-step", 1, "threshold change per encoder detent")
+	fs.StringVar(&c.InfluxURL, "influx-url", "", "InfluxDB v2 URL to write readings to, e.g. http://influx:8086")
+	fs.StringVar(&c.InfluxToken, "influx-token", "", "InfluxDB API token")
+	fs.StringVar(&c.InfluxOrg, "influx-org", "", "InfluxDB organization")
+	fs.StringVar(&c.InfluxBucket, "influx-bucket", "gardener", "InfluxDB bucket")
+	fs.DurationVar(&c.InfluxFlush, "influx-flush", 10*time.Second, "interval between InfluxDB batch writes")
+	fs.StringVar(&c.MetricsPush, "metrics-push", "", "Prometheus pushgateway URL to push metrics to, e.g. http://pushgateway:9091")
+	fs.DurationVar(&c.MetricsPushInterval, "metrics-push-interval", time.Minute, "interval between metrics pushes")
+	fs.Float64Var(&c.StoreDelta, "store-delta", 0, "only store a reading when a field changed by more than this (0 stores every reading)")
+	fs.DurationVar(&c.StoreMaxInterval, "store-max-interval", 5*time.Minute, "store a reading at least this often even if unchanged (0 to disable)")
+	fs.Var(&c.Bounds, "bounds", "plausible reading ranges, e.g. soil=0:100,temperature=-40:85")
+	fs.Var(&c.Precision, "precision", "decimals to round readings to, e.g. temperature=1,pressure=0")
+	fs.Var(&c.Calibrate, "calibrate", "linear calibration as name=scale:offset, e.g. env.temperature=1:1.2,soil=1.05:0")
+	fs.BoolVar(&c.HoldLastValid, "hold-last-valid", false, "replace implausible readings with the last valid value instead of dropping them")
+	fs.Float64Var(&c.TickerJitter, "ticker-jitter", 0, "percentage by which each sensor read interval randomly varies")
+	fs.IntVar(&c.MaxConcurrentReads, "max-concurrent-reads", 0, "maximum simultaneous device reads, 0 for no limit")
+	fs.BoolVar(&c.PublishOnShutdown, "publish-on-shutdown", false, "publish a final reading of every sensor when shutting down")
+	fs.Var(&c.ShutdownOrder, "shutdown-order", "order actuators are switched off in on shutdown, e.g. valve,pump")
+	fs.DurationVar(&c.ShutdownStepDelay, "shutdown-step-delay", 0, "pause between switching actuators off on shutdown")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", 5*time.Second, "time allowed for each shutdown phase")
+	fs.IntVar(&c.LEDRed, "led-red", -1, "moisture RGB LED red pin, -1 to disable")
+	fs.IntVar(&c.LEDGreen, "led-green", -1, "moisture RGB LED green pin, -1 to disable")
+	fs.IntVar(&c.LEDBlue, "led-blue", -1, "moisture RGB LED blue pin, -1 to disable")
+	fs.Float64Var(&c.LEDDry, "led-dry", 30, "soil moisture below which the LED is red")
+	fs.Float64Var(&c.LEDMoist, "led-moist", 50, "soil moisture from which the LED is green")
+	fs.StringVar(&c.DisplayType, "display", "oled", "display type, oled or hd44780")
+	fs.Var(&c.DisplayLabels, "display-label", "replace display strings, e.g. soil=Boden,low=Trocken ab")
+	fs.StringVar(&c.DisplayPages, "display-pages", "", "YAML file of the metric, label and format of each display line")
+	fs.DurationVar(&c.DisplayRotate, "display-rotate", 5*time.Second, "how long each display page is shown")
+	fs.StringVar(&c.TempUnit, "temp-unit", "C", "unit temperatures are displayed in, C or F")
+	fs.BoolVar(&c.ReadingTimestamps, "reading-timestamps", false, "add the read time to JSON readings")
+	fs.BoolVar(&c.ReadingQuality, "reading-quality", false, "add a quality field to JSON readings")
+	fs.IntVar(&c.CompressOver, "compress-over", 0, "gzip payloads larger than this many bytes onto <topic>/gz, 0 to disable")
+	fs.IntVar(&c.PublishQueue, "publish-queue", 256, "sensor messages held for a slow broker before the oldest are dropped, 0 to publish synchronously")
+	fs.DurationVar(&c.StateGetInterval, "state-get-interval", 5*time.Second, "least time between state dumps requested on c/state/get")
+	c.Quantiles.Set("0.1,0.5,0.9")
+	fs.Var(&c.Quantiles, "quantiles", "quantiles of each sensor to estimate in the daily summary, empty to disable")
+	fs.DurationVar(&c.QuantileInterval, "quantile-interval", 0, "publish today's quantiles on d/summary/quantiles this often, 0 to disable")
+	fs.Var(&c.DeviceTopics, "device-topic", "device state topics, e.g. soil=home/garden/soil")
+	fs.Var(&c.DeviceCommands, "device-command", "extra device command topics, e.g. pump=home/garden/pump/set")
+	fs.Var(&c.TopicAliases, "topic-alias", "also publish a topic under a legacy name, e.g. d/soil=garden/soil")
+	fs.StringVar(&c.RulesFile, "rules", "", "YAML file of automation rules")
+	fs.DurationVar(&c.RulesInterval, "rules-interval", 10*time.Second, "how often the automation rules are evaluated")
+	fs.StringVar(&c.BridgeFile, "bridge", "", "YAML file of source topics to republish under normalized topics")
+	fs.DurationVar(&c.PressureTrendWindow, "pressure-trend-window", 3*time.Hour, "period the pressure tendency covers")
+	fs.Float64Var(&c.PressureTrendThreshold, "pressure-trend-threshold", 1, "pressure change in hPa over the window that counts as rising or falling")
+	fs.DurationVar(&c.LivenessTimeout, "liveness-timeout", time.Minute, "how long without a sensor tick before /livez fails")
+	fs.DurationVar(&c.HTTPBindRetry, "http-bind-retry", 30*time.Second, "how long to retry binding the HTTP port at startup")
+	fs.BoolVar(&c.ListDevices, "list-devices", false, "print the devices the configuration would create and exit")
+	fs.BoolVar(&c.JSON, "json", false, "print -list-devices output as JSON")
+	fs.BoolVar(&c.RecoverPanics, "recover-panics", true, "recover and restart after goroutine panics instead of crashing")
 
 	// Logging flags
-	flag.StringVar(&config.Log.Level, "log-level", "info", "log level: debug, info, warn, error")
-	flag.Var(&config.Log.Output, "log-output", "log output: stdout, stderr, file")
-	flag.Var(&config.Log.Format, "log-format", "log format: text, json")
-	flag.StringVar(&config.Log.FilePath, "log-file", "gardener.log", "log file path (when log-output=file)")
-	config.Log.Output.Set("file")
-	config.Log.Format.Set("text")
+	fs.StringVar(&c.Log.Level, "log-level", "info", "log level: debug, info, warn, error")
+	fs.Var(&c.LogSinks, "log-output", "log outputs with optional format: stdout, stderr, file, e.g. stdout:text,file:json")
+	fs.BoolVar(&c.StrictLogging, "strict-logging", false, "exit if the log file cannot be written instead of logging to stderr")
+	fs.Var(&c.Log.Format, "log-format", "log format: text, json")
+	fs.Float64Var(&c.LogDelta, "log-delta", 0, "least change for a reading to be logged at info rather than debug, 0 to log every reading at info")
+	fs.Var(&c.LogAttrs, "log-attr", "key=value attributes added to every log line, e.g. zone=front,env=prod")
+	fs.StringVar(&c.Log.FilePath, "log-file", "", "log file path (when log-output=file), default <station-name>.log")
+	c.Log.Output.Set("file")
+	c.Log.Format.Set("text")
 }
 
 func main() {
 	flag.Parse()
+	c, err := LoadConfig("")
+	if err != nil {
+		log.Fatalf("Bad config: %v", err)
+	}
+	config = c
+	validation := validateConfig()
+	if !validation.OK() {
+		log.Fatalf("Bad config:\n%s", validation.Report())
+	}
+	if location, err = time.LoadLocation(config.Timezone); err != nil {
+		log.Fatalf("Bad timezone: %v", err)
+	}
+
+	if config.ListDevices {
+		if err := listDevices(os.Stdout, config.JSON); err != nil {
+			log.Fatalf("Failed to list devices: %v", err)
+		}
+		return
+	}
 
 	// Initialize structured logging
-	_, err := utils.InitLogger(config.Log)
+	config.Log.FilePath = logFilePath(config.Log.FilePath, config.StationName)
+	err = initLoggingFallback()
 	if err != nil {
 		log.Fatalf("Failed to initialize logger: %v", err)
 	}
+	for _, w := range validation.Warnings {
+		slog.Warn("config warning", "option", w.Option, "problem", w.Message)
+	}
 
 	slog.Info("starting gardener",
 		"station", config.StationName,
 		"mock", config.Mock,
 		"broker", config.Broker,
 		"log_level", config.Log.Level,
-		"log_output", config.Log.Output,
+		"log_output", config.LogSinks.String(),
+		"calibration", config.Calibrate.String(),
+		"pins", config.Pins.String(),
 	)
 
 	// Enable mocking in devices if mock flag is set
@@ -65,7 +597,9 @@ func main() {
 	}
 
 	gardener := &Gardener{}
-	gardener.Init()
+	if err := gardener.Init(); err != nil {
+		log.Fatalf("gardener: %v", err)
+	}
 	gardener.Start()
 
 	// Handle OS signals and call Stop() for graceful shutdown
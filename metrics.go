@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Metrics is a minimal Prometheus registry served on /metrics in the
+// text exposition format. Metrics are declared once with Gauge or
+// Counter and then updated with Set or Add, optionally with label
+// name/value pairs.
+type Metrics struct {
+	mu         sync.Mutex
+	metrics    map[string]*metric
+	collectors []func()
+}
+
+type metric struct {
+	typ    string
+	help   string
+	series map[string]float64
+}
+
+func newMetrics() *Metrics {
+	return &Metrics{metrics: make(map[string]*metric)}
+}
+
+func (m *Metrics) declare(name, typ, help string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.metrics[name] = &metric{typ: typ, help: help, series: make(map[string]float64)}
+}
+
+// Gauge declares a gauge.
+func (m *Metrics) Gauge(name, help string) {
+	m.declare(name, "gauge", help)
+}
+
+// Counter declares a counter.
+func (m *Metrics) Counter(name, help string) {
+	m.declare(name, "counter", help)
+}
+
+// Collect registers fn to be called before every scrape, for metrics
+// that are sampled rather than updated as they happen.
+func (m *Metrics) Collect(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.collectors = append(m.collectors, fn)
+}
+
+// Set sets the value of a series.
+func (m *Metrics) Set(name string, v float64, labels ...string) {
+	m.update(name, labels, func(old float64) float64 { return v })
+}
+
+// Add adds v to a series.
+func (m *Metrics) Add(name string, v float64, labels ...string) {
+	m.update(name, labels, func(old float64) float64 { return old + v })
+}
+
+// Inc adds one to a series.
+func (m *Metrics) Inc(name string, labels ...string) {
+	m.Add(name, 1, labels...)
+}
+
+func (m *Metrics) update(name string, labels []string, fn func(float64) float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mt, ok := m.metrics[name]
+	if !ok {
+		panic("metric not declared: " + name)
+	}
+	key := labelString(labels)
+	mt.series[key] = fn(mt.series[key])
+}
+
+func labelString(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var parts []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		parts = append(parts, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.write(w)
+}
+
+// write runs the collectors and writes every metric in the text
+// exposition format.
+func (m *Metrics) write(w io.Writer) {
+	m.mu.Lock()
+	collectors := m.collectors
+	m.mu.Unlock()
+	for _, fn := range collectors {
+		fn()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.metrics))
+	for name := range m.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		mt := m.metrics[name]
+		if len(mt.series) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, mt.help, name, mt.typ)
+
+		keys := make([]string, 0, len(mt.series))
+		for k := range mt.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s%s %g\n", name, k, mt.series[k])
+		}
+	}
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// initMetrics declares the station metrics and registers the sensor
+// and runtime collectors.
+func (g *Gardener) initMetrics() {
+	m := newMetrics()
+	m.Gauge("gardener_soil_moisture", "Latest soil moisture reading.")
+	m.Gauge("gardener_temperature_celsius", "Latest env sensor temperature.")
+	m.Gauge("gardener_humidity_percent", "Latest env sensor relative humidity.")
+	m.Gauge("gardener_pressure", "Latest env sensor barometric pressure.")
+	m.Gauge("gardener_pump_on", "1 when the pump is on.")
+	m.Gauge("gardener_watering_response_per_second", "Soil moisture gained per second of pumping in the last watering.")
+	m.Counter("gardener_reading_anomalies_total", "Readings discarded as implausible.")
+	m.Counter("gardener_publish_dropped_total", "Sensor messages dropped from a full publish queue, by topic.")
+	m.Counter("gardener_handler_panics_total", "Message handler panics recovered, by topic.")
+	m.Collect(func() {
+		r := g.readings.Snapshot()
+		if !r.SoilTime.IsZero() {
+			m.Set("gardener_soil_moisture", r.Soil)
+		}
+		if !r.EnvTime.IsZero() {
+			m.Set("gardener_temperature_celsius", r.Temperature)
+			m.Set("gardener_humidity_percent", r.Humidity)
+			m.Set("gardener_pressure", r.Pressure)
+		}
+		m.Set("gardener_pump_on", boolFloat(r.Pump))
+	})
+
+	registerGoCollector(m)
+	registerProcessCollector(m)
+	g.metrics = m
+}
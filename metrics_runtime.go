@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// registerGoCollector exports goroutine, heap and GC statistics under
+// the same names as the Prometheus Go collector.
+func registerGoCollector(m *Metrics) {
+	m.Gauge("go_goroutines", "Number of goroutines that currently exist.")
+	m.Gauge("go_threads", "Number of OS threads created.")
+	m.Gauge("go_memstats_heap_alloc_bytes", "Number of heap bytes allocated and still in use.")
+	m.Gauge("go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.")
+	m.Gauge("go_memstats_sys_bytes", "Number of bytes obtained from system.")
+	m.Gauge("go_memstats_last_gc_time_seconds", "Number of seconds since 1970 of last garbage collection.")
+	m.Counter("go_gc_cycles_total", "Number of completed GC cycles.")
+	m.Counter("go_gc_pause_seconds_total", "Total GC stop-the-world pause time.")
+	m.Collect(func() {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		threads, _ := runtime.ThreadCreateProfile(nil)
+
+		m.Set("go_goroutines", float64(runtime.NumGoroutine()))
+		m.Set("go_threads", float64(threads))
+		m.Set("go_memstats_heap_alloc_bytes", float64(ms.HeapAlloc))
+		m.Set("go_memstats_heap_inuse_bytes", float64(ms.HeapInuse))
+		m.Set("go_memstats_sys_bytes", float64(ms.Sys))
+		m.Set("go_memstats_last_gc_time_seconds", float64(ms.LastGC)/1e9)
+		m.Set("go_gc_cycles_total", float64(ms.NumGC))
+		m.Set("go_gc_pause_seconds_total", float64(ms.PauseTotalNs)/1e9)
+	})
+}
+
+// registerProcessCollector exports process statistics. The memory and
+// file descriptor metrics come from /proc and are skipped where that
+// is not available.
+func registerProcessCollector(m *Metrics) {
+	start := time.Now()
+	m.Gauge("process_start_time_seconds", "Start time of the process since unix epoch in seconds.")
+	m.Gauge("process_resident_memory_bytes", "Resident memory size in bytes.")
+	m.Gauge("process_open_fds", "Number of open file descriptors.")
+	m.Collect(func() {
+		m.Set("process_start_time_seconds", float64(start.Unix()))
+
+		if buf, err := os.ReadFile("/proc/self/statm"); err == nil {
+			fields := strings.Fields(string(buf))
+			if len(fields) > 1 {
+				if pages, err := strconv.ParseFloat(fields[1], 64); err == nil {
+					m.Set("process_resident_memory_bytes", pages*float64(os.Getpagesize()))
+				}
+			}
+		}
+		if fds, err := os.ReadDir("/proc/self/fd"); err == nil {
+			m.Set("process_open_fds", float64(len(fds)))
+		}
+	})
+}
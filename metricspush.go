@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// pushURL returns the pushgateway URL grouping this station's metrics.
+func pushURL(base string) string {
+	return strings.TrimRight(base, "/") + "/metrics/job/gardener/instance/" + url.PathEscape(config.StationName)
+}
+
+// push replaces the station's metrics on the pushgateway at target.
+func (m *Metrics) push(client *http.Client, target string) error {
+	var body bytes.Buffer
+	m.write(&body)
+	req, err := http.NewRequest(http.MethodPut, target, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("metrics push: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// startMetricsPush pushes the metrics to config.MetricsPush every
+// config.MetricsPushInterval.
+func (g *Gardener) startMetricsPush() {
+	if config.MetricsPush == "" {
+		return
+	}
+	target := pushURL(config.MetricsPush)
+	client := &http.Client{Timeout: 10 * time.Second}
+	g.every("metrics-push", config.MetricsPushInterval, func(time.Time) {
+		if err := g.metrics.push(client, target); err != nil {
+			slog.Error("metrics push failed", "error", err)
+		}
+	})
+	slog.Info("metrics push enabled", "url", target, "interval", config.MetricsPushInterval)
+}
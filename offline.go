@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rustyeddy/otto/messenger"
+)
+
+// Offline policies, for what the station does once the broker has been
+// unreachable for config.OfflineAfter.
+const (
+	// offlineNone carries on as if connected.
+	offlineNone = "none"
+
+	// offlineLocal waters on the soil thresholds alone, driving the
+	// pump directly rather than through c/pump on the broker.
+	offlineLocal = "local-autonomous"
+
+	// offlineConservative stops the pump and disables automatic
+	// watering until the broker is back.
+	offlineConservative = "conservative"
+)
+
+// linkTopic carries the pings that show the broker is reachable.
+const linkTopic = "d/link"
+
+func validateOfflinePolicy(p string) error {
+	switch p {
+	case offlineNone, offlineLocal, offlineConservative:
+		return nil
+	}
+	return fmt.Errorf("unknown %q: want %s, %s or %s", p, offlineNone, offlineLocal, offlineConservative)
+}
+
+// brokerLink tells whether the broker is reachable. The messenger does
+// not report its connection state, so, as with confirmed publishes, the
+// station pings itself through the broker and counts it offline once no
+// ping has come back for config.OfflineAfter.
+type brokerLink struct {
+	mu       sync.Mutex
+	lastSeen time.Time
+	offline  bool
+}
+
+// offlinePolicy returns the policy in force: config.OfflinePolicy while
+// the broker is offline, offlineNone while it is online.
+func (g *Gardener) offlinePolicy() string {
+	if g.link == nil {
+		return offlineNone
+	}
+	g.link.mu.Lock()
+	defer g.link.mu.Unlock()
+	if !g.link.offline {
+		return offlineNone
+	}
+	return config.OfflinePolicy
+}
+
+func (g *Gardener) handleLink(*messenger.Msg) error {
+	g.link.mu.Lock()
+	g.link.lastSeen = now()
+	back := g.link.offline
+	g.link.offline = false
+	g.link.mu.Unlock()
+	if back {
+		slog.Info("broker reachable again, leaving offline mode", "policy", config.OfflinePolicy)
+		g.water.online()
+	}
+	return nil
+}
+
+// checkLink pings the broker, when connected, and enters offline mode
+// once the last ping back is older than config.OfflineAfter.
+func (g *Gardener) checkLink(connected bool) {
+	t := now()
+	if connected {
+		g.publish(linkTopic, []byte(strconv.FormatInt(t.Unix(), 10)))
+	}
+	g.link.mu.Lock()
+	if g.link.offline || t.Sub(g.link.lastSeen) < config.OfflineAfter {
+		g.link.mu.Unlock()
+		return
+	}
+	g.link.offline = true
+	since := g.link.lastSeen
+	g.link.mu.Unlock()
+
+	slog.Warn("broker offline, entering offline mode", "policy", config.OfflinePolicy, "since", since)
+	g.water.offline(config.OfflinePolicy)
+}
+
+// startBrokerLink watches the broker link when an offline policy is
+// set. A station that has not connected goes offline
+// config.OfflineAfter after startup.
+func (g *Gardener) startBrokerLink() {
+	if config.OfflinePolicy == offlineNone {
+		return
+	}
+	g.link = &brokerLink{lastSeen: now()}
+	g.subscribe(linkTopic, g.handleLink)
+	interval := max(config.OfflineAfter/4, time.Second)
+	g.every("broker-link", interval, func(time.Time) {
+		g.checkLink(g.health.connected.Load())
+	})
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// displayRows is how many lines a page has, on the OLED and the
+	// 16x2 LCD alike.
+	displayRows = 2
+
+	// displayHold keeps a setting being changed with the encoder on the
+	// display before the pages take it back.
+	displayHold = 10 * time.Second
+
+	// barWidth is the width of a bar gauge in characters.
+	barWidth = 10
+)
+
+// DisplayEntry is one line of the display pages: a metric from the
+// readings, the label shown with it, and how it is formatted. An empty
+// format shows the label and the value with its unit, "bar" a bar
+// gauge of a 0-100 value, and anything else is a fmt format given the
+// label and the value, e.g. "%s %.0f%%".
+type DisplayEntry struct {
+	Metric string `yaml:"metric"`
+	Label  string `yaml:"label"`
+	Format string `yaml:"format"`
+}
+
+// defaultPages shows the soil and the pump together, then the env
+// readings.
+var defaultPages = []DisplayEntry{
+	{Metric: "soil"},
+	{Metric: "pump"},
+	{Metric: "temperature"},
+	{Metric: "humidity"},
+	{Metric: "pressure"},
+}
+
+func (e DisplayEntry) validate() error {
+	if _, ok := ruleMetrics[e.Metric]; !ok && e.Metric != "pump" {
+		return fmt.Errorf("unknown metric %q", e.Metric)
+	}
+	if e.Format != "" && e.Format != "bar" && !strings.Contains(e.Format, "%") {
+		return fmt.Errorf("format %q has no verbs: want empty, bar or a fmt format", e.Format)
+	}
+	return nil
+}
+
+// loadDisplayPages reads a YAML list of display entries.
+func loadDisplayPages(path string) ([]DisplayEntry, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []DisplayEntry
+	if err := yaml.Unmarshal(buf, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, e := range entries {
+		if err := e.validate(); err != nil {
+			return nil, fmt.Errorf("%s: entry %d: %w", path, i+1, err)
+		}
+	}
+	return entries, nil
+}
+
+// render returns the entry's line for the readings, or "-" for a value
+// not yet read.
+func (e DisplayEntry) render(r Readings) string {
+	label := e.Label
+	if label == "" {
+		label = displayLabel(e.Metric)
+	}
+	if e.Metric == "pump" {
+		state := "off"
+		if r.Pump {
+			state = "on"
+		}
+		return label + " " + state
+	}
+	v, ok := ruleMetrics[e.Metric](r)
+	if !ok {
+		return label + " -"
+	}
+	switch e.Format {
+	case "":
+		return label + " " + displayValue(e.Metric, v)
+	case "bar":
+		n := int(min(max(v, 0), 100) * barWidth / 100)
+		return label + " " + strings.Repeat("#", n) + strings.Repeat(".", barWidth-n)
+	}
+	if (e.Metric == "temperature" || e.Metric == "soiltemp") && strings.EqualFold(config.TempUnit, "F") {
+		v = v*9/5 + 32
+	}
+	return fmt.Sprintf(e.Format, label, v)
+}
+
+// initDisplayPages loads config.DisplayPages, or the default pages.
+func (g *Gardener) initDisplayPages() {
+	g.pages = defaultPages
+	if config.DisplayPages == "" {
+		return
+	}
+	pages, err := loadDisplayPages(config.DisplayPages)
+	if err != nil {
+		panic(err)
+	}
+	g.pages = pages
+}
+
+// holdDisplay keeps the pages off the display for displayHold while
+// something else is shown.
+func (g *Gardener) holdDisplay() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.displayHeld = time.Now().Add(displayHold)
+}
+
+// startDisplayPages shows the display pages in turn from the latest
+// readings, moving on every config.DisplayRotate.
+func (g *Gardener) startDisplayPages() {
+	if !config.EnableDisplay || len(g.pages) == 0 {
+		return
+	}
+	pages := (len(g.pages) + displayRows - 1) / displayRows
+	ticker := time.NewTicker(config.DisplayRotate)
+	g.goSafe("display", func() {
+		page := 0
+		for {
+			select {
+			case <-g.ctx.Done():
+				return
+			case <-ticker.C:
+				g.mu.Lock()
+				held := time.Now().Before(g.displayHeld)
+				g.mu.Unlock()
+				if held {
+					continue
+				}
+				g.showPage(page)
+				page = (page + 1) % pages
+			}
+		}
+	})
+}
+
+func (g *Gardener) showPage(page int) {
+	r := g.readings.Snapshot()
+	g.display.Clear()
+	for line := 0; line < displayRows; line++ {
+		i := page*displayRows + line
+		if i >= len(g.pages) {
+			break
+		}
+		g.display.Printf(line, "%s", g.pages[i].render(r))
+	}
+	if err := g.display.Draw(); err != nil {
+		slog.Error("display draw failed", "error", err)
+	}
+}
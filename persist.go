@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// stateSaveInterval is how often the control state is saved.
+const stateSaveInterval = time.Minute
+
+// controlState is the watering control state that must survive a
+// restart, saved to state.json in config.DataDir.
+type controlState struct {
+	Saved       time.Time     `json:"saved"`
+	Mode        string        `json:"mode"`
+	ManualUntil time.Time     `json:"manual_until"`
+	Summary     *DailySummary `json:"summary"`
+	DeepWatered time.Time     `json:"deep_watered"`
+	Profile     string        `json:"profile"`
+
+	// Day and RanToday are the daily run, counted for the daily cap,
+	// and RestUntil the end of the rest after a watering limit.
+	Day       string        `json:"day"`
+	RanToday  time.Duration `json:"ran_today"`
+	RestUntil time.Time     `json:"rest_until"`
+
+	// Rain and RainAt are the last rainfall report, which holds off
+	// watering while it is fresh.
+	Rain   float64   `json:"rain"`
+	RainAt time.Time `json:"rain_at"`
+}
+
+func statePath() string {
+	return filepath.Join(config.DataDir, "state.json")
+}
+
+// saveState writes the control state.
+func (g *Gardener) saveState() {
+	if config.DataDir == "" {
+		return
+	}
+
+	st := controlState{Saved: now()}
+	st.Mode, st.ManualUntil = g.water.override()
+	st.Summary = g.summary.current()
+	st.DeepWatered = g.water.lastDeepWater()
+	st.Profile = g.water.Profile().Name
+	st.Day, st.RanToday, st.RestUntil = g.water.dailyRun()
+	st.Rain, st.RainAt = g.weather.lastRain()
+
+	jbuf, err := json.Marshal(st)
+	if err != nil {
+		slog.Error("state marshal failed", "error", err)
+		return
+	}
+	if err := writeAtomic(statePath(), jbuf); err != nil {
+		slog.Error("state save failed", "error", err)
+	}
+}
+
+// restoreState loads the control state saved by a previous run.
+func (g *Gardener) restoreState() {
+	if config.DataDir == "" {
+		return
+	}
+	jbuf, err := os.ReadFile(statePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		slog.Error("state restore failed", "error", err)
+		return
+	}
+	var st controlState
+	if err := json.Unmarshal(jbuf, &st); err != nil {
+		slog.Error("state restore failed", "error", err)
+		return
+	}
+
+	g.water.restoreOverride(st.Mode, st.ManualUntil)
+	g.water.restoreDeepWater(st.DeepWatered)
+	g.restoreProfile(st.Profile)
+	g.water.restoreDailyRun(st.Day, st.RanToday, st.RestUntil)
+	g.weather.restoreRain(st.Rain, st.RainAt)
+	if st.Summary != nil {
+		g.summary.restore(st.Summary)
+	}
+	slog.Info("control state restored", "saved", st.Saved, "mode", g.water.Mode())
+}
+
+func (g *Gardener) startStateSaver() {
+	if config.DataDir == "" {
+		return
+	}
+	g.every("state-saver", stateSaveInterval, func(time.Time) {
+		g.saveState()
+	})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestStateKeepsDailyRun saves the daily run, the rest after a limit
+// and the last rain, and checks a restarted station restores them.
+func TestStateKeepsDailyRun(t *testing.T) {
+	g, _ := testGardener(t)
+	dir := t.TempDir()
+	config.DataDir = dir
+	config.RainTopic = "weather/rain"
+	config.WeatherMaxAge = 6 * time.Hour
+	if err := g.Init(); err != nil {
+		t.Fatal(err)
+	}
+	today := now().Format(time.DateOnly)
+	restUntil := now().Add(20 * time.Minute).Round(0)
+	rainAt := now().Add(-time.Hour).Round(0)
+	g.water.mu.Lock()
+	g.water.day, g.water.ranToday, g.water.restUntil = today, 7*time.Minute, restUntil
+	g.water.mu.Unlock()
+	g.weather.mu.Lock()
+	g.weather.rain, g.weather.rainAt = 4.5, rainAt
+	g.weather.mu.Unlock()
+	g.saveState()
+	g.Stop()
+
+	g2, _ := testGardener(t)
+	config.DataDir, config.RainTopic, config.WeatherMaxAge = dir, "weather/rain", 6*time.Hour
+	if err := g2.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(g2.Stop)
+	day, ran, rest := g2.water.dailyRun()
+	if day != today || ran != 7*time.Minute || !rest.Equal(restUntil) {
+		t.Errorf("daily run = %s, %s, rest until %s; want %s, 7m0s, %s", day, ran, rest, today, restUntil)
+	}
+	if rain, at := g2.weather.lastRain(); rain != 4.5 || !at.Equal(rainAt) {
+		t.Errorf("last rain = %g at %s, want 4.5 at %s", rain, at, rainAt)
+	}
+}
+
+func TestRestoreDropsStaleDailyRun(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config.WeatherMaxAge = time.Hour
+
+	w := &WaterController{}
+	yesterday := now().AddDate(0, 0, -1).Format(time.DateOnly)
+	w.restoreDailyRun(yesterday, 7*time.Minute, now().Add(-time.Minute))
+	if _, ran, rest := w.dailyRun(); ran != 0 || !rest.IsZero() {
+		t.Errorf("restored yesterday's run %s and a past rest %s, want neither", ran, rest)
+	}
+
+	wf := &weatherFeed{}
+	wf.restoreRain(4.5, now().Add(-2*time.Hour))
+	if rain, at := wf.lastRain(); rain != 0 || !at.IsZero() {
+		t.Errorf("restored stale rain %g at %s, want none", rain, at)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultPins are the GPIO pins of the reference board.
+func defaultPins() pinMap {
+	return pinMap{
+		"on":   17,
+		"off":  27,
+		"soil": 22,
+		"pump": 5,
+		"env":  6,
+	}
+}
+
+// pinMap is a comma separated list of name=pin flag values overriding
+// the default pins, e.g. "pump=23,soil=24". A negative pin leaves the
+// device without one.
+type pinMap map[string]int
+
+func (m *pinMap) String() string {
+	if m == nil {
+		return ""
+	}
+	var parts []string
+	for name, pin := range *m {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, pin))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (m *pinMap) Set(v string) error {
+	if *m == nil {
+		*m = defaultPins()
+	}
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, pin, ok := strings.Cut(part, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("%q: expected name=pin", part)
+		}
+		p, err := strconv.Atoi(pin)
+		if err != nil {
+			return fmt.Errorf("%q: bad pin %q", part, pin)
+		}
+		(*m)[name] = p
+	}
+	return nil
+}
+
+// pin returns the pin of the named device, or -1 if it has none.
+func (m pinMap) pin(name string) int {
+	if p, ok := m[name]; ok {
+		return p
+	}
+	return -1
+}
+
+// validatePinMap returns an error for a pin name no device uses, which
+// is most likely a typo, or for an enabled device with no pin.
+func validatePinMap() error {
+	defaults := defaultPins()
+	var problems []string
+	for _, name := range slices.Sorted(maps.Keys(config.Pins)) {
+		if _, ok := defaults[name]; !ok {
+			problems = append(problems, fmt.Sprintf("unknown pin %q", name))
+		}
+	}
+	required := func(enabled bool, device string, names ...string) {
+		for _, name := range names {
+			if enabled && config.Pins.pin(name) < 0 {
+				problems = append(problems, fmt.Sprintf("the %s is enabled but pin %q is missing", device, name))
+			}
+		}
+	}
+	required(config.EnableButtons, "buttons", "on", "off")
+	required(config.EnableSoil, "soil sensor", "soil")
+	required(config.EnablePump, "pump", "pump")
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// pinClaim is a GPIO pin a device needs and the direction it drives it.
+type pinClaim struct {
+	pin    int
+	device string
+	output bool
+}
+
+func (c pinClaim) String() string {
+	dir := "input"
+	if c.output {
+		dir = "output"
+	}
+	return fmt.Sprintf("%s (%s)", c.device, dir)
+}
+
+// pinClaims lists the GPIO pins the enabled devices need.
+func pinClaims() []pinClaim {
+	var claims []pinClaim
+	claim := func(device string, pin int, output bool) {
+		if pin >= 0 {
+			claims = append(claims, pinClaim{pin: pin, device: device, output: output})
+		}
+	}
+
+	if config.EnableButtons {
+		claim("button on", config.Pins.pin("on"), false)
+		claim("button off", config.Pins.pin("off"), false)
+	}
+	if config.EnableSoil {
+		claim("vh400 soil", config.Pins.pin("soil"), false)
+	}
+	if config.EnablePump {
+		claim("relay pump", config.Pins.pin("pump"), true)
+		claim("button pump-feedback", config.PumpFeedbackPin, false)
+	}
+	// Bad entries are reported when the zones are set up.
+	zones, _ := zoneSpecs()
+	for _, z := range zones {
+		claim("relay "+zoneValveName(z.name), z.pin, true)
+	}
+	if config.EncoderA >= 0 && config.EncoderB >= 0 {
+		claim("encoder a", config.EncoderA, false)
+		claim("encoder b", config.EncoderB, false)
+		claim("encoder push", config.EncoderPush, false)
+	}
+	if config.LEDRed >= 0 && config.LEDGreen >= 0 && config.LEDBlue >= 0 {
+		claim("led red", config.LEDRed, true)
+		claim("led green", config.LEDGreen, true)
+		claim("led blue", config.LEDBlue, true)
+	}
+	return claims
+}
+
+// validatePins returns an error naming every pin claimed by more than
+// one device, such as a relay output on a pin a button already reads.
+func validatePins(claims []pinClaim) error {
+	byPin := make(map[int][]pinClaim)
+	for _, c := range claims {
+		byPin[c.pin] = append(byPin[c.pin], c)
+	}
+	var pins []int
+	for pin, cs := range byPin {
+		if len(cs) > 1 {
+			pins = append(pins, pin)
+		}
+	}
+	if len(pins) == 0 {
+		return nil
+	}
+	sort.Ints(pins)
+	var conflicts []string
+	for _, pin := range pins {
+		cs := byPin[pin]
+		for _, c := range cs[1:] {
+			conflicts = append(conflicts, fmt.Sprintf("pin %d: %s conflicts with %s", pin, c, cs[0]))
+		}
+	}
+	return errors.New(strings.Join(conflicts, "; "))
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePins(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		claims []pinClaim
+		wants  []string // each must appear in the error; none means no error
+	}{
+		{"distinct pins", []pinClaim{
+			{pin: 17, device: "button on"},
+			{pin: 5, device: "relay pump", output: true},
+		}, nil},
+		{"button and relay share a pin", []pinClaim{
+			{pin: 17, device: "button on"},
+			{pin: 17, device: "relay pump", output: true},
+		}, []string{"pin 17", "relay pump (output)", "button on (input)"}},
+		{"three devices on one pin", []pinClaim{
+			{pin: 22, device: "vh400 soil"},
+			{pin: 22, device: "button off"},
+			{pin: 22, device: "relay valve-beds", output: true},
+		}, []string{"pin 22", "vh400 soil", "button off", "relay valve-beds"}},
+		{"two conflicts", []pinClaim{
+			{pin: 6, device: "led red", output: true},
+			{pin: 6, device: "encoder a"},
+			{pin: 5, device: "relay pump", output: true},
+			{pin: 5, device: "button pump-feedback"},
+		}, []string{"pin 5: button pump-feedback (input) conflicts with relay pump (output)",
+			"pin 6: encoder a (input) conflicts with led red (output)"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validatePins(tc.claims)
+			if len(tc.wants) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("no error for a pin conflict")
+			}
+			for _, want := range tc.wants {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPinClaimsConflict(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config.EnableButtons, config.EnablePump = true, true
+	config.Pins = defaultPins()
+	config.Pins["pump"] = config.Pins["on"]
+
+	err := validatePins(pinClaims())
+	if err == nil {
+		t.Fatal("no error for the pump relay on the on button's pin")
+	}
+	for _, want := range []string{"button on", "relay pump"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not name %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// BenchmarkReadingPipeline times the per-tick processing of a soil
+// reading, filter, round and compensate, decide and format, for one
+// zone and for fifty, so a feature that makes the control loop costly
+// shows up here first.
+func BenchmarkReadingPipeline(b *testing.B) {
+	saved := config
+	b.Cleanup(func() { config = saved })
+	config.SoilTempCoeff = 0.1
+	config.SoilTempRef = 20
+
+	g := &Gardener{}
+	g.readings.setSoilTemp(15, now())
+	decimals, _ := precision("soil")
+
+	for _, zones := range []int{1, 50} {
+		b.Run(fmt.Sprintf("zones=%d", zones), func(b *testing.B) {
+			watering := make([]bool, zones)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for z := range zones {
+					value := float64((i+z)%100) + 0.123
+					if !plausible("soil", value) {
+						continue
+					}
+					value, _ = g.compensateSoil(round("soil", value))
+					value = round("soil", value)
+					watering[z], _ = decide(watering[z], value, 30, 60)
+					_ = []byte(fmt.Sprintf("%5.*f", decimals, value))
+				}
+			}
+		})
+	}
+}
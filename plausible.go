@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// bounds is the plausible range of a reading.
+type bounds struct {
+	Min float64
+	Max float64
+}
+
+func (b bounds) contains(v float64) bool {
+	return !math.IsNaN(v) && v >= b.Min && v <= b.Max
+}
+
+// defaultBounds are the plausible ranges of each reading: soil
+// moisture in percent, and the BME280's operating range for
+// temperature and pressure in hPa.
+var defaultBounds = map[string]bounds{
+	"soil":        {0, 100},
+	"temperature": {-40, 85},
+	"humidity":    {0, 100},
+	"pressure":    {300, 1100},
+}
+
+// boundsMap is a comma separated list of name=min:max flag values,
+// e.g. "soil=5:95,temperature=-10:50".
+type boundsMap map[string]bounds
+
+func (m *boundsMap) String() string {
+	if m == nil {
+		return ""
+	}
+	var parts []string
+	for name, b := range *m {
+		parts = append(parts, fmt.Sprintf("%s=%g:%g", name, b.Min, b.Max))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (m *boundsMap) Set(v string) error {
+	if *m == nil {
+		*m = make(boundsMap)
+	}
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, rng, ok := strings.Cut(part, "=")
+		lo, hi, ok2 := strings.Cut(rng, ":")
+		if !ok || !ok2 {
+			return fmt.Errorf("%q: expected name=min:max", part)
+		}
+		min, err := strconv.ParseFloat(lo, 64)
+		if err != nil {
+			return fmt.Errorf("%q: %w", part, err)
+		}
+		max, err := strconv.ParseFloat(hi, 64)
+		if err != nil {
+			return fmt.Errorf("%q: %w", part, err)
+		}
+		if min > max {
+			return fmt.Errorf("%q: min is above max", part)
+		}
+		(*m)[name] = bounds{Min: min, Max: max}
+	}
+	return nil
+}
+
+// finite reports whether v is a number that can be published, NaN and
+// the infinities breaking JSON consumers and Prometheus alike.
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// plausible reports whether v is finite and inside the bounds
+// configured for the named reading. Finite readings without bounds are
+// always plausible.
+func plausible(name string, v float64) bool {
+	if !finite(v) {
+		return false
+	}
+	b, ok := config.Bounds[name]
+	if !ok {
+		b, ok = defaultBounds[name]
+	}
+	return !ok || b.contains(v)
+}
+
+// anomaly logs and counts an implausible reading.
+func (g *Gardener) anomaly(name string, v float64) {
+	slog.Warn("implausible reading discarded", "sensor", name, "value", v, "hold", config.HoldLastValid)
+	g.metrics.Inc("gardener_reading_anomalies_total", "sensor", name)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// forceSoil makes the emulator report v, which c/emulator/set cannot
+// carry when it is not finite, and takes a soil reading.
+func forceSoil(g *Gardener, v float64) {
+	g.emu.mu.Lock()
+	g.emu.s.Soil = &v
+	g.emu.mu.Unlock()
+	g.sensors["soil"](now())
+}
+
+// TestNonFiniteNotPublished feeds NaN and ±Inf through the soil
+// publish path and checks only finite values reach d/soil.
+func TestNonFiniteNotPublished(t *testing.T) {
+	for _, hold := range []bool{false, true} {
+		t.Run(fmt.Sprintf("hold=%t", hold), func(t *testing.T) {
+			g, b := testGardener(t)
+			config.HoldLastValid = hold
+			config.SoilPublishOnChange = false
+			config.SoilWarmup = 0
+			if err := g.Init(); err != nil {
+				t.Fatal(err)
+			}
+			g.Start()
+			t.Cleanup(g.Stop)
+
+			decimals, _ := precision("soil")
+			payload := func(v float64) string { return fmt.Sprintf("%5.*f", decimals, v) }
+			forceSoil(g, 40)
+			b.waitFor(t, "d/soil", payload(40))
+			for _, v := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+				forceSoil(g, v)
+			}
+			forceSoil(g, 41)
+			b.waitFor(t, "d/soil", payload(41))
+
+			want := []string{payload(40), payload(41)}
+			if hold {
+				// Each bad reading is replaced by the last valid one.
+				want = []string{payload(40), payload(40), payload(40), payload(40), payload(41)}
+			}
+			got := b.received("d/soil")
+			for _, p := range got {
+				v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+				if err != nil || !finite(v) {
+					t.Errorf("published %q on d/soil", p)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("d/soil = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultPrecision is how many decimals each reading keeps: soil as it
+// has always been published, the others about what the sensors resolve.
+var defaultPrecision = map[string]int{
+	"soil":        2,
+	"soiltemp":    1,
+	"temperature": 1,
+	"humidity":    1,
+	"pressure":    1,
+}
+
+// precisionMap is a comma separated list of name=decimals flag values,
+// e.g. "temperature=1,pressure=0".
+type precisionMap map[string]int
+
+func (m *precisionMap) String() string {
+	if m == nil {
+		return ""
+	}
+	var parts []string
+	for name, p := range *m {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, p))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (m *precisionMap) Set(v string) error {
+	if *m == nil {
+		*m = make(precisionMap)
+	}
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, decimals, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("%q: expected name=decimals", part)
+		}
+		p, err := strconv.Atoi(decimals)
+		if err != nil || p < 0 || p > 6 {
+			return fmt.Errorf("%q: decimals must be 0 to 6", part)
+		}
+		(*m)[name] = p
+	}
+	return nil
+}
+
+// precision returns the decimals kept for the named reading.
+func precision(name string) (int, bool) {
+	p, ok := config.Precision[name]
+	if !ok {
+		p, ok = defaultPrecision[name]
+	}
+	return p, ok
+}
+
+// round rounds v to the precision of the named reading, once it is
+// final, so MQTT, the API and InfluxDB all carry the same value.
+// Readings without a precision are left alone.
+func round(name string, v float64) float64 {
+	p, ok := precision(name)
+	if !ok || math.IsNaN(v) || math.IsInf(v, 0) {
+		return v
+	}
+	scale := math.Pow(10, float64(p))
+	return math.Round(v*scale) / scale
+}
+
+// roundFields rounds each field of a reading in place.
+func roundFields(fields map[string]float64) {
+	for name, v := range fields {
+		fields[name] = round(name, v)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// pressureSample is one pressure reading in hPa.
+type pressureSample struct {
+	t time.Time
+	p float64
+}
+
+// pressureTrend keeps the pressure readings over a window so the
+// tendency, the barometer's rise or fall, can be worked out.
+type pressureTrend struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	samples []pressureSample
+}
+
+// PressureTendency is published on d/pressure/trend.
+type PressureTendency struct {
+	Trend  string  `json:"trend"`
+	Delta  float64 `json:"delta"`
+	Window string  `json:"window"`
+}
+
+// add records p taken at t and returns the tendency over the window,
+// or false until the readings span the whole window.
+func (pt *pressureTrend) add(t time.Time, p float64) (PressureTendency, bool) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	pt.samples = append(pt.samples, pressureSample{t, p})
+
+	// Drop what is older than needed, keeping the last sample at or
+	// beyond the start of the window to measure from.
+	start := t.Add(-pt.window)
+	i := 0
+	for i+1 < len(pt.samples) && !pt.samples[i+1].t.After(start) {
+		i++
+	}
+	pt.samples = pt.samples[i:]
+
+	first := pt.samples[0]
+	if first.t.After(start) {
+		return PressureTendency{}, false
+	}
+	delta := p - first.p
+	trend := "steady"
+	switch {
+	case delta >= config.PressureTrendThreshold:
+		trend = "rising"
+	case delta <= -config.PressureTrendThreshold:
+		trend = "falling"
+	}
+	return PressureTendency{Trend: trend, Delta: delta, Window: pt.window.String()}, true
+}
+
+// updatePressureTrend feeds an env pressure reading into the trend and
+// publishes the tendency once there is enough history.
+func (g *Gardener) updatePressureTrend(t time.Time, p float64) {
+	tend, ok := g.pressure.add(t, p)
+	if !ok || !config.PublishTopics {
+		return
+	}
+	jbuf, err := json.Marshal(tend)
+	if err != nil {
+		slog.Error("pressure trend marshal failed", "error", err)
+		return
+	}
+	g.publish("d/pressure/trend", jbuf)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rustyeddy/otto/messenger"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// profileNormal is the profile of the configured thresholds.
+	profileNormal = "normal"
+
+	// profileVacation is the built-in profile for unattended periods,
+	// unless -profiles defines its own.
+	profileVacation = "vacation"
+)
+
+// Profile is a named set of watering settings swapped in at runtime.
+// MaxRun stops any one watering after that long, and DailyRuntime
+// stops watering for the day once the pump has run that long in total,
+// or DailyLiters once it has pumped that much at -pump-flow-rate.
+// Zero leaves a limit off. A profile other than normal also ignores
+// -threshold-schedule.
+type Profile struct {
+	Name         string        `yaml:"name" json:"name"`
+	Low          float64       `yaml:"low" json:"low"`
+	High         float64       `yaml:"high" json:"high"`
+	MaxRun       time.Duration `yaml:"max_run" json:"max_run"`
+	DailyRuntime time.Duration `yaml:"daily_runtime" json:"daily_runtime"`
+	DailyLiters  float64       `yaml:"daily_liters" json:"daily_liters"`
+}
+
+func (p *Profile) validate() error {
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	if p.Low >= p.High {
+		return fmt.Errorf("low %g must be below high %g", p.Low, p.High)
+	}
+	if p.MaxRun < 0 || p.DailyRuntime < 0 || p.DailyLiters < 0 {
+		return errors.New("limits must not be negative")
+	}
+	if p.DailyLiters > 0 && config.PumpFlowRate <= 0 {
+		return errors.New("daily_liters needs -pump-flow-rate")
+	}
+	return nil
+}
+
+// dailyCap returns the most the pump may run in a day, or zero.
+func (p *Profile) dailyCap() time.Duration {
+	c := p.DailyRuntime
+	if p.DailyLiters > 0 && config.PumpFlowRate > 0 {
+		l := time.Duration(p.DailyLiters / config.PumpFlowRate * float64(time.Minute))
+		if c == 0 || l < c {
+			c = l
+		}
+	}
+	return c
+}
+
+// defaultProfiles are the normal profile of the configured thresholds
+// and a conservative vacation profile: ten points drier before
+// watering, two minute waterings and ten minutes of pumping a day.
+func defaultProfiles() map[string]*Profile {
+	low := max(config.LowThreshold-10, 0)
+	return map[string]*Profile{
+		profileNormal: {Name: profileNormal, Low: config.LowThreshold, High: config.HighThreshold},
+		profileVacation: {Name: profileVacation, Low: low, High: max(config.HighThreshold-10, low+1),
+			MaxRun: 2 * time.Minute, DailyRuntime: 10 * time.Minute},
+	}
+}
+
+// loadProfiles reads a YAML list of profiles over the defaults.
+func loadProfiles(path string) (map[string]*Profile, error) {
+	profiles := defaultProfiles()
+	if path == "" {
+		return profiles, nil
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []*Profile
+	if err := yaml.Unmarshal(buf, &list); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, p := range list {
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("%s: profile %d: %w", path, i+1, err)
+		}
+		profiles[p.Name] = p
+	}
+	return profiles, nil
+}
+
+// initProfiles loads config.ProfilesFile, panicking on a malformed one,
+// and starts with config.Profile.
+func (g *Gardener) initProfiles() {
+	profiles, err := loadProfiles(config.ProfilesFile)
+	if err != nil {
+		panic(err)
+	}
+	g.profiles = profiles
+	name := config.Profile
+	if name == "" {
+		name = profileNormal
+	}
+	p, ok := profiles[name]
+	if !ok {
+		panic(fmt.Sprintf("unknown profile %q", name))
+	}
+	g.water.SetProfile(p)
+}
+
+// restoreProfile restores a saved profile, unless -profile chose one.
+func (g *Gardener) restoreProfile(name string) {
+	if config.Profile != "" || name == "" {
+		return
+	}
+	p, ok := g.profiles[name]
+	if !ok {
+		slog.Warn("saved profile no longer defined", "profile", name)
+		return
+	}
+	g.water.SetProfile(p)
+}
+
+// setProfile switches to the named profile and publishes it.
+func (g *Gardener) setProfile(name string) error {
+	p, ok := g.profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+	g.water.SetProfile(p)
+	g.publishProfile()
+	return nil
+}
+
+// publishProfile publishes the active profile on d/profile.
+func (g *Gardener) publishProfile() {
+	g.publish("d/profile", []byte(g.water.Profile().Name))
+}
+
+func (g *Gardener) handleProfile(msg *messenger.Msg) error {
+	return g.setProfile(strings.TrimSpace(string(msg.Data)))
+}
+
+// serveProfile returns the active profile on GET /api/profile and
+// switches to another on POST /api/profile?name=vacation.
+func (g *Gardener) serveProfile(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if err := g.setProfile(r.URL.Query().Get("name")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(g.water.Profile()); err != nil {
+		slog.Error("profile encode failed", "error", err)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"log/slog"
+	"sync"
+)
+
+// gzipSuffix marks a topic whose payload is gzip compressed: d/state
+// becomes d/state/gz.
+const gzipSuffix = "/gz"
+
+// warnedAliases records the aliases already warned about.
+var warnedAliases sync.Map
+
+// publish publishes data on topic and on every legacy alias configured
+// for it, so consumers can move to a renamed topic gradually. Each
+// alias is warned about once, as a reminder to remove it.
+func (g *Gardener) publish(topic string, data []byte) {
+	g.send(topic, data)
+	for _, alias := range config.TopicAliases[topic] {
+		if _, warned := warnedAliases.LoadOrStore(alias, true); !warned {
+			slog.Warn("publishing to deprecated topic alias", "topic", topic, "alias", alias)
+		}
+		g.send(alias, data)
+	}
+}
+
+// send hands a message to the publish queue, or publishes it at once
+// without one.
+func (g *Gardener) send(topic string, data []byte) {
+	if g.pubq != nil {
+		g.pubq.push(topic, data)
+		return
+	}
+	g.pub(topic, data)
+}
+
+// pub publishes data, gzip compressed on the topic with gzipSuffix if
+// it is larger than config.CompressOver bytes.
+func (g *Gardener) pub(topic string, data []byte) {
+	if config.CompressOver > 0 && len(data) > config.CompressOver {
+		if gz, err := gzipBytes(data); err != nil {
+			slog.Error("payload compression failed", "topic", topic, "error", err)
+		} else {
+			topic, data = topic+gzipSuffix, gz
+		}
+	}
+	g.mqttPublish(topic, data)
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
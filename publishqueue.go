@@ -0,0 +1,137 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// priorityPrefixes are the topics that go out ahead of sensor data and
+// are never dropped: commands, and the pump and emergency stop state.
+var priorityPrefixes = []string{"c/", "d/pump", "d/emergency"}
+
+func priorityTopic(topic string) bool {
+	for _, p := range priorityPrefixes {
+		if strings.HasPrefix(topic, p) {
+			return true
+		}
+	}
+	return false
+}
+
+type queuedMsg struct {
+	topic string
+	data  []byte
+}
+
+// publishQueue decouples the sensor callbacks from the broker. Publishes
+// are queued and a single goroutine hands them to the messenger, so a
+// slow broker backs up the queue rather than a sensor's reads. Sensor
+// data is held to size messages, dropping the oldest when full; the
+// priority messages have their own queue that is sent first and never
+// drops.
+type publishQueue struct {
+	g    *Gardener
+	size int
+
+	mu       sync.Mutex
+	priority []queuedMsg
+	sensor   []queuedMsg
+	sending  bool
+	closed   bool
+	wake     chan struct{}
+	idle     chan struct{}
+}
+
+func newPublishQueue(g *Gardener, size int) *publishQueue {
+	q := &publishQueue{g: g, size: size, wake: make(chan struct{}, 1)}
+	g.goSafe("publisher", q.run)
+	return q
+}
+
+// push queues a message. Once the queue is closed it is dropped.
+func (q *publishQueue) push(topic string, data []byte) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		slog.Debug("publish queue closed, message dropped", "topic", topic)
+		return
+	}
+	m := queuedMsg{topic: topic, data: data}
+	if priorityTopic(topic) {
+		q.priority = append(q.priority, m)
+	} else {
+		if len(q.sensor) >= q.size {
+			dropped := q.sensor[0]
+			q.sensor = q.sensor[1:]
+			q.g.metrics.Inc("gardener_publish_dropped_total", "topic", dropped.topic)
+			slog.Debug("publish queue full, oldest dropped", "topic", dropped.topic, "size", q.size)
+		}
+		q.sensor = append(q.sensor, m)
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next takes the next message to send, priority first.
+func (q *publishQueue) next() (queuedMsg, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var m queuedMsg
+	switch {
+	case len(q.priority) > 0:
+		m, q.priority = q.priority[0], q.priority[1:]
+	case len(q.sensor) > 0:
+		m, q.sensor = q.sensor[0], q.sensor[1:]
+	default:
+		q.sending = false
+		if q.idle != nil {
+			close(q.idle)
+			q.idle = nil
+		}
+		return m, false
+	}
+	q.sending = true
+	return m, true
+}
+
+func (q *publishQueue) run() {
+	for range q.wake {
+		for {
+			m, ok := q.next()
+			if !ok {
+				break
+			}
+			q.g.pub(m.topic, m.data)
+		}
+	}
+}
+
+// close stops the publisher goroutine once it has sent what is queued.
+func (q *publishQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.wake)
+	}
+}
+
+// drain waits for everything queued to be sent.
+func (q *publishQueue) drain() error {
+	q.mu.Lock()
+	if len(q.priority) == 0 && len(q.sensor) == 0 && !q.sending {
+		q.mu.Unlock()
+		return nil
+	}
+	if q.idle == nil {
+		q.idle = make(chan struct{})
+	}
+	idle := q.idle
+	q.mu.Unlock()
+	<-idle
+	return nil
+}
@@ -0,0 +1,470 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/rustyeddy/devices"
+	"github.com/rustyeddy/devices/button"
+	"github.com/rustyeddy/devices/relay"
+	"github.com/rustyeddy/otto/messenger"
+)
+
+// Pump wraps the pump relay. Commands arriving on c/pump go through
+// the Pump rather than straight to the relay so the station can check
+// that the pump actually did what it was told.
+type Pump struct {
+	*relay.Relay
+
+	g *Gardener
+
+	// out is what on and off switch: the relay, or a stand-in for it
+	// under test.
+	out switcher
+
+	// feedback is an optional current-sense or flow input that goes
+	// high when water is moving.
+	feedback *button.Button
+
+	mu        sync.Mutex
+	running   bool
+	startedAt time.Time
+	lastFlow  time.Time
+	lastRan   time.Time
+
+	// exercising is set while a maintenance run is going, which is
+	// left out of the watering accounting.
+	exercising bool
+
+	// testing is set while an installer's test run is going. It is
+	// also exercising, as a test is not watering either.
+	testing bool
+
+	// soakStop is closed to abort the soak sequence in progress.
+	soakStop chan struct{}
+
+	// pendingOff is an off held back until the minimum runtime passes.
+	pendingOff *time.Timer
+
+	// cutoff forces the pump off once it has run for
+	// config.PumpMaxRunSeconds since the last "on".
+	cutoff *time.Timer
+}
+
+// switcher is an output that can be switched on and off.
+type switcher interface {
+	On() error
+	Off() error
+}
+
+func newPump(g *Gardener, r *relay.Relay) *Pump {
+	return &Pump{Relay: r, g: g, out: r, lastRan: time.Now()}
+}
+
+// initFeedback attaches a current-sense or flow input on pin.
+func (p *Pump) initFeedback(pin int) error {
+	fb, err := button.New("pump-feedback", pin)
+	if err != nil {
+		return err
+	}
+	fb.RegisterEventHandler(func(evt *devices.DeviceEvent) {
+		defer p.g.recoverPanic("pump-feedback")
+		switch evt.Type {
+		case devices.DeviceEventRisingEdge:
+			p.mu.Lock()
+			p.lastFlow = time.Now()
+			p.mu.Unlock()
+		}
+	})
+	p.feedback = fb
+	return nil
+}
+
+// pumpCommand is a c/pump payload. It is either a bare "on" or "off",
+// or a JSON object that may ask for a soak sequence:
+//
+//	{"state": "on", "cycles": 3, "on": "30s", "off": "2m"}
+type pumpCommand struct {
+	State  string `json:"state"`
+	Cycles int    `json:"cycles"`
+	On     string `json:"on"`
+	Off    string `json:"off"`
+}
+
+func parsePumpCommand(data []byte) (pumpCommand, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return pumpCommand{State: string(data)}, nil
+	}
+	var cmd pumpCommand
+	err := json.Unmarshal(data, &cmd)
+	return cmd, err
+}
+
+// soak returns the soak sequence the command asks for, filling in
+// anything it leaves out from the configured defaults.
+func (cmd pumpCommand) soak() (Soak, error) {
+	s := Soak{Cycles: config.SoakCycles, On: config.SoakOn, Off: config.SoakOff}
+	if cmd.Cycles > 0 {
+		s.Cycles = cmd.Cycles
+	}
+	var err error
+	if cmd.On != "" {
+		if s.On, err = time.ParseDuration(cmd.On); err != nil {
+			return s, err
+		}
+	}
+	if cmd.Off != "" {
+		if s.Off, err = time.ParseDuration(cmd.Off); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// errSoakInProgress rejects a command that would disturb a soak.
+var errSoakInProgress = errors.New("soak in progress")
+
+// PumpAck is published on d/pump/ack for every pump command, saying
+// whether it was honored and, if not, why.
+type PumpAck struct {
+	Command string `json:"command"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// HandleMsg handles commands from c/pump and acknowledges them.
+func (p *Pump) HandleMsg(msg *messenger.Msg) error {
+	err := p.handle(msg)
+	ack := PumpAck{Command: string(msg.Data), Status: "accepted"}
+	if err != nil {
+		ack.Status, ack.Reason = "rejected", err.Error()
+	}
+	jbuf, jerr := json.Marshal(ack)
+	if jerr != nil {
+		slog.Error("pump ack marshal failed", "error", jerr)
+		return err
+	}
+	p.g.publish("d/pump/ack", jbuf)
+	return err
+}
+
+func (p *Pump) handle(msg *messenger.Msg) error {
+	cmd, err := parsePumpCommand(msg.Data)
+	if err != nil {
+		return fmt.Errorf("bad pump command %q: %w", msg.Data, err)
+	}
+
+	switch cmd.State {
+	case "on":
+		s, err := cmd.soak()
+		if err != nil {
+			return fmt.Errorf("bad pump command %q: %w", msg.Data, err)
+		}
+		if s.Cycles > 1 {
+			return p.Soak(s)
+		}
+		return p.On()
+	case "off":
+		return p.Off()
+	default:
+		return fmt.Errorf("unknown pump command: %q", msg.Data)
+	}
+}
+
+// On turns the pump on. It is refused while a soak sequence runs. It
+// cancels an off that is waiting out the minimum runtime.
+func (p *Pump) On() error {
+	p.g.commanded("pump")
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.g.emergencyStopped() {
+		return errEmergencyStop
+	}
+	if p.pendingOff != nil {
+		p.pendingOff.Stop()
+		p.pendingOff = nil
+		slog.Info("deferred pump off cancelled")
+	}
+	if p.soakStop != nil {
+		return errSoakInProgress
+	}
+	if p.exercising {
+		// A real watering takes over the maintenance or test run.
+		p.exercising = false
+		p.testing = false
+		p.running = false
+		p.startedAt = time.Time{}
+	}
+	if !p.running {
+		p.g.summary.Watering()
+	}
+	if err := p.on(); err != nil {
+		return err
+	}
+	p.armCutoff()
+	return nil
+}
+
+// armCutoff starts, or on a repeated "on" restarts, the maximum
+// runtime cutoff. p.mu must be held.
+func (p *Pump) armCutoff() {
+	maxRun := time.Duration(config.PumpMaxRunSeconds) * time.Second
+	if maxRun <= 0 {
+		return
+	}
+	if p.cutoff != nil {
+		p.cutoff.Stop()
+	}
+	var t *time.Timer
+	t = time.AfterFunc(maxRun, func() { p.cutoffReached(t, maxRun) })
+	p.cutoff = t
+}
+
+// cutoffReached forces the pump off after running for maxRun, tells
+// the water controller and publishes the off.
+func (p *Pump) cutoffReached(t *time.Timer, maxRun time.Duration) {
+	p.mu.Lock()
+	if p.cutoff != t || !p.running {
+		p.mu.Unlock()
+		return
+	}
+	slog.Warn("pump max runtime reached, forcing off", "max", maxRun, "runtime", time.Since(p.startedAt))
+	err := p.forceOff()
+	p.mu.Unlock()
+	if err != nil {
+		slog.Error("pump cutoff off failed", "error", err)
+		return
+	}
+	p.g.water.pumpCutOff()
+	p.g.zones.pumpCutOff()
+	p.g.publish("c/pump", []byte("off"))
+}
+
+// Off turns the pump off, aborting any soak sequence. To protect the
+// motor from short-cycling, an off within config.PumpMinRuntime of the
+// pump starting is held back until the minimum runtime has passed.
+func (p *Pump) Off() error {
+	p.g.commanded("pump")
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running && config.PumpMinRuntime > 0 {
+		remaining := config.PumpMinRuntime - time.Since(p.startedAt)
+		if remaining > 0 {
+			if p.pendingOff == nil {
+				slog.Info("pump off deferred for minimum runtime", "remaining", remaining)
+				p.pendingOff = time.AfterFunc(remaining, func() {
+					if err := p.Off(); err != nil {
+						slog.Error("deferred pump off failed", "error", err)
+					}
+				})
+			}
+			return nil
+		}
+	}
+	return p.forceOff()
+}
+
+// ForceOff turns the pump off at once, ignoring the minimum runtime.
+// It is for safety paths such as shutdown.
+func (p *Pump) ForceOff() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.forceOff()
+}
+
+// forceOff aborts any soak or deferred off and switches the relay off.
+// p.mu must be held.
+func (p *Pump) forceOff() error {
+	if p.cutoff != nil {
+		p.cutoff.Stop()
+		p.cutoff = nil
+	}
+	if p.pendingOff != nil {
+		p.pendingOff.Stop()
+		p.pendingOff = nil
+	}
+	if p.soakStop != nil {
+		close(p.soakStop)
+		p.soakStop = nil
+		slog.Info("soak aborted")
+	}
+	return p.off()
+}
+
+// on switches the relay on and, if there is a feedback input, checks
+// that flow shows up within config.PumpFeedbackTimeout. p.mu must be
+// held.
+func (p *Pump) on() error {
+	if err := p.out.On(); err != nil {
+		return err
+	}
+	if p.running {
+		return nil
+	}
+	p.running = true
+	p.startedAt = time.Now()
+	p.g.readings.setPump(true)
+	if !p.exercising {
+		p.g.response.begin()
+	}
+	slog.Info("pump on")
+
+	if p.feedback != nil {
+		started := p.startedAt
+		time.AfterFunc(config.PumpFeedbackTimeout, func() {
+			p.verify(started)
+		})
+	}
+	return nil
+}
+
+// off switches the relay off. p.mu must be held.
+func (p *Pump) off() error {
+	if err := p.out.Off(); err != nil {
+		return err
+	}
+	if p.running {
+		runtime := time.Since(p.startedAt)
+		if p.testing {
+			slog.Info("pump test run complete", "runtime", runtime)
+		} else if p.exercising {
+			slog.Info("pump maintenance run complete", "runtime", runtime)
+		} else {
+			p.g.summary.PumpRan(runtime)
+			p.g.response.ran(runtime)
+			slog.Info("pump off", "runtime", runtime)
+		}
+		p.lastRan = time.Now()
+	}
+	p.running = false
+	p.exercising = false
+	p.testing = false
+	p.g.readings.setPump(false)
+	return nil
+}
+
+// verify raises an alert if the pump run that began at started is
+// still going but no flow has been seen since.
+func (p *Pump) verify(started time.Time) {
+	p.mu.Lock()
+	failed := p.running && p.startedAt.Equal(started) && p.lastFlow.Before(started)
+	p.mu.Unlock()
+
+	if failed {
+		p.g.Alert("pump_failed_to_start",
+			fmt.Sprintf("no pump feedback within %s of pump on", config.PumpFeedbackTimeout))
+	}
+}
+
+// Soak is a pulsed watering of Cycles repetitions of On then Off,
+// which many plants absorb better than one long watering.
+type Soak struct {
+	Cycles int
+	On     time.Duration
+	Off    time.Duration
+}
+
+// Soak starts a soak sequence in the background. A manual Off aborts
+// it.
+func (p *Pump) Soak(s Soak) error {
+	if s.Cycles < 1 || s.On <= 0 || s.Off < 0 {
+		return fmt.Errorf("invalid soak %d x %s/%s", s.Cycles, s.On, s.Off)
+	}
+
+	p.g.commanded("pump")
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.g.emergencyStopped() {
+		return errEmergencyStop
+	}
+	if p.soakStop != nil {
+		return errSoakInProgress
+	}
+	stop := make(chan struct{})
+	p.soakStop = stop
+	p.g.summary.Watering()
+
+	// Not goSafe, which would restart a soak that panicked, but Stop
+	// still waits for it.
+	p.g.running.Add(1)
+	go func() {
+		defer p.g.running.Done()
+		defer p.g.recoverPanic("soak")
+		p.runSoak(s, stop)
+	}()
+	return nil
+}
+
+func (p *Pump) runSoak(s Soak, stop chan struct{}) {
+	maxRun := time.Duration(config.PumpMaxRunSeconds) * time.Second
+	slog.Info("soak started", "cycles", s.Cycles, "on", s.On, "off", s.Off)
+
+	var total time.Duration
+	for i := 1; i <= s.Cycles; i++ {
+		on := s.On
+		if maxRun > 0 && total+on > maxRun {
+			on = maxRun - total
+			slog.Warn("soak limited by pump max runtime", "cycle", i, "max", maxRun)
+		}
+		if on <= 0 {
+			break
+		}
+
+		p.mu.Lock()
+		if p.soakStop != stop {
+			p.mu.Unlock()
+			return
+		}
+		slog.Info("soak cycle", "cycle", i, "cycles", s.Cycles, "on", on)
+		err := p.on()
+		p.mu.Unlock()
+		if err != nil {
+			slog.Error("soak pump on failed", "cycle", i, "error", err)
+			break
+		}
+		total += on
+
+		if !sleepOrStop(on, stop) {
+			return
+		}
+		p.mu.Lock()
+		err = p.off()
+		p.mu.Unlock()
+		if err != nil {
+			slog.Error("soak pump off failed", "cycle", i, "error", err)
+			break
+		}
+
+		if i < s.Cycles && !sleepOrStop(s.Off, stop) {
+			return
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.soakStop == stop {
+		p.soakStop = nil
+		if err := p.off(); err != nil {
+			slog.Error("soak pump off failed", "error", err)
+		}
+		slog.Info("soak complete", "runtime", total)
+	}
+}
+
+// sleepOrStop waits for d and reports false if stop was closed first.
+func sleepOrStop(d time.Duration, stop <-chan struct{}) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-stop:
+		return false
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// switchRelay is a pump relay stand-in that records every switch.
+type switchRelay struct {
+	mu       sync.Mutex
+	on       bool
+	switches []bool
+}
+
+func (r *switchRelay) On() error  { return r.set(true) }
+func (r *switchRelay) Off() error { return r.set(false) }
+
+func (r *switchRelay) set(on bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.on = on
+	r.switches = append(r.switches, on)
+	return nil
+}
+
+func (r *switchRelay) state() (bool, []bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.on, slices.Clone(r.switches)
+}
+
+// waitSwitches waits until the relay has been switched n times.
+func (r *switchRelay) waitSwitches(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(testWait)
+	for _, sw := r.state(); len(sw) < n; _, sw = r.state() {
+		if time.Now().After(deadline) {
+			t.Fatalf("relay switched %v, want %d switches", sw, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestStopEndsSoak stops the station during a soak's on pulse and
+// during the rest between pulses, and checks the relay ends off and
+// the soak does not switch it back on.
+func TestStopEndsSoak(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		soak     Soak
+		switches int // how far into the soak to stop
+	}{
+		{"during pulse", Soak{Cycles: 3, On: time.Hour, Off: 10 * time.Millisecond}, 1},
+		{"between pulses", Soak{Cycles: 3, On: 10 * time.Millisecond, Off: time.Hour}, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g, _ := testGardener(t)
+			if err := g.Init(); err != nil {
+				t.Fatal(err)
+			}
+			r := &switchRelay{}
+			g.pump.out = r
+			g.Start()
+			t.Cleanup(g.Stop)
+
+			if err := g.pump.Soak(tc.soak); err != nil {
+				t.Fatal(err)
+			}
+			r.waitSwitches(t, tc.switches)
+			g.Stop()
+
+			time.Sleep(50 * time.Millisecond)
+			on, switches := r.state()
+			if on || !slices.Equal(switches[len(switches)-1:], []bool{false}) {
+				t.Errorf("relay switched %v after Stop, want it to end off", switches)
+			}
+			if g.readings.Snapshot().Pump {
+				t.Error("readings show the pump on after Stop")
+			}
+		})
+	}
+}
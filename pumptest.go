@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rustyeddy/otto/messenger"
+)
+
+// pumpTestMax caps a test run however long it is asked to be.
+const pumpTestMax = 10 * time.Second
+
+// errPumpRunning refuses a test run while the pump is already running.
+var errPumpRunning = errors.New("pump already running")
+
+// testDuration returns the test run asked for by s, a duration such as
+// "5s", or config.PumpTestDuration when s is empty, capped at
+// pumpTestMax.
+func testDuration(s string) (time.Duration, error) {
+	d := config.PumpTestDuration
+	if s = strings.TrimSpace(s); s != "" {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, fmt.Errorf("bad test duration %q: %w", s, err)
+		}
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("test duration %s must be positive", d)
+	}
+	return min(d, pumpTestMax), nil
+}
+
+// Test runs the pump for d to check the plumbing. It ignores the
+// minimum runtime but not the emergency stop, is not counted as
+// watering, and is switched off by a timer on the station, so it ends
+// even if whoever started it goes away.
+func (p *Pump) Test(d time.Duration) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.g.emergencyStopped() {
+		return errEmergencyStop
+	}
+	if p.running || p.soakStop != nil {
+		return errPumpRunning
+	}
+	p.exercising, p.testing = true, true
+	slog.Info("pump test run", "duration", d)
+	if err := p.on(); err != nil {
+		p.exercising, p.testing = false, false
+		return err
+	}
+	time.AfterFunc(d, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if !p.testing {
+			return
+		}
+		if err := p.forceOff(); err != nil {
+			slog.Error("pump test off failed", "error", err)
+		}
+	})
+	return nil
+}
+
+// handlePumpTest runs a test on c/pump/test, the payload optionally
+// giving its duration.
+func (g *Gardener) handlePumpTest(msg *messenger.Msg) error {
+	d, err := testDuration(string(msg.Data))
+	if err != nil {
+		return err
+	}
+	return g.pump.Test(d)
+}
+
+// servePumpTest runs a test on POST /api/pump/test?duration=5s.
+func (g *Gardener) servePumpTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if g.pump == nil {
+		http.Error(w, "no pump on this station", http.StatusNotFound)
+		return
+	}
+	d, err := testDuration(r.URL.Query().Get("duration"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := g.pump.Test(d); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "running", "duration": d.String()})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// p2 estimates one quantile of a stream with the P² algorithm of Jain
+// and Chlamtac, keeping five markers however many values it sees.
+type p2 struct {
+	p   float64
+	n   [5]int     // marker positions
+	np  [5]float64 // desired positions
+	dn  [5]float64 // desired position increments
+	q   [5]float64 // marker heights
+	cnt int
+}
+
+func newP2(p float64) *p2 {
+	return &p2{p: p, dn: [5]float64{0, p / 2, p, (1 + p) / 2, 1}}
+}
+
+func (e *p2) observe(v float64) {
+	if e.cnt < 5 {
+		e.q[e.cnt] = v
+		e.cnt++
+		if e.cnt == 5 {
+			slices.Sort(e.q[:])
+			for i := range e.n {
+				e.n[i] = i
+				e.np[i] = 4 * e.dn[i]
+			}
+		}
+		return
+	}
+	e.cnt++
+
+	var k int
+	switch {
+	case v < e.q[0]:
+		e.q[0], k = v, 0
+	case v >= e.q[4]:
+		e.q[4], k = v, 3
+	default:
+		for k = 0; k < 3 && v >= e.q[k+1]; k++ {
+		}
+	}
+	for i := k + 1; i < 5; i++ {
+		e.n[i]++
+	}
+	for i := range e.np {
+		e.np[i] += e.dn[i]
+	}
+
+	for i := 1; i < 4; i++ {
+		d := e.np[i] - float64(e.n[i])
+		if (d >= 1 && e.n[i+1]-e.n[i] > 1) || (d <= -1 && e.n[i-1]-e.n[i] < -1) {
+			s := 1
+			if d < 0 {
+				s = -1
+			}
+			q := e.parabolic(i, float64(s))
+			if e.q[i-1] >= q || q >= e.q[i+1] {
+				q = e.q[i] + float64(s)*(e.q[i+s]-e.q[i])/float64(e.n[i+s]-e.n[i])
+			}
+			e.q[i] = q
+			e.n[i] += s
+		}
+	}
+}
+
+func (e *p2) parabolic(i int, s float64) float64 {
+	n0, n1, n2 := float64(e.n[i-1]), float64(e.n[i]), float64(e.n[i+1])
+	return e.q[i] + s/(n2-n0)*((n1-n0+s)*(e.q[i+1]-e.q[i])/(n2-n1)+(n2-n1-s)*(e.q[i]-e.q[i-1])/(n1-n0))
+}
+
+// value returns the estimate, exact while fewer than five values have
+// been seen.
+func (e *p2) value() float64 {
+	if e.cnt == 0 {
+		return math.NaN()
+	}
+	if e.cnt < 5 {
+		seen := slices.Clone(e.q[:e.cnt])
+		slices.Sort(seen)
+		return seen[int(math.Round(e.p*float64(e.cnt-1)))]
+	}
+	return e.q[2]
+}
+
+// quantileList is a comma separated list of quantiles between 0 and 1,
+// e.g. "0.1,0.5,0.9".
+type quantileList []float64
+
+func (l *quantileList) String() string {
+	if l == nil {
+		return ""
+	}
+	var parts []string
+	for _, q := range *l {
+		parts = append(parts, strconv.FormatFloat(q, 'f', -1, 64))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *quantileList) Set(v string) error {
+	var qs quantileList
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		q, err := strconv.ParseFloat(part, 64)
+		if err != nil || !(q > 0 && q < 1) {
+			return fmt.Errorf("quantile %q: want a number between 0 and 1", part)
+		}
+		qs = append(qs, q)
+	}
+	*l = qs
+	return nil
+}
+
+// quantileName names a quantile as it is reported, e.g. 0.9 as "p90"
+// and 0.995 as "p99.5".
+func quantileName(q float64) string {
+	return "p" + strconv.FormatFloat(math.Round(q*1e6)/1e4, 'f', -1, 64)
+}
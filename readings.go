@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Readings is a snapshot of the latest value from every sensor and the
+// pump state.
+type Readings struct {
+	Soil         float64   `json:"soil"`
+	SoilTime     time.Time `json:"soil_time"`
+	SoilRaw      float64   `json:"soil_raw"`
+	SoilTemp     float64   `json:"soil_temp"`
+	SoilTempTime time.Time `json:"soil_temp_time"`
+	Temperature  float64   `json:"temperature"`
+	Humidity     float64   `json:"humidity"`
+	Pressure     float64   `json:"pressure"`
+	EnvTime      time.Time `json:"env_time"`
+	Pump         bool      `json:"pump"`
+}
+
+// readingCache holds the latest Readings, updated from the ticker
+// callbacks.
+type readingCache struct {
+	mu sync.Mutex
+	r  Readings
+}
+
+func (c *readingCache) setSoil(v, raw float64, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.r.Soil = v
+	c.r.SoilRaw = raw
+	c.r.SoilTime = t
+}
+
+func (c *readingCache) setSoilTemp(v float64, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.r.SoilTemp = v
+	c.r.SoilTempTime = t
+}
+
+// setEnv updates the env fields present in fields, leaving the others
+// at their last valid value.
+func (c *readingCache) setEnv(fields map[string]float64, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := fields["temperature"]; ok {
+		c.r.Temperature = v
+	}
+	if v, ok := fields["humidity"]; ok {
+		c.r.Humidity = v
+	}
+	if v, ok := fields["pressure"]; ok {
+		c.r.Pressure = v
+	}
+	c.r.EnvTime = t
+}
+
+func (c *readingCache) setPump(on bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.r.Pump = on
+}
+
+// Snapshot returns a copy of the current readings.
+func (c *readingCache) Snapshot() Readings {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.r
+}
+
+// serveSensors serves the latest readings on GET /api/sensors.
+func (g *Gardener) serveSensors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(g.readings.Snapshot()); err != nil {
+		slog.Error("sensors encode failed", "error", err)
+	}
+}
+
+// Reading qualities, saying how a value was obtained.
+const (
+	qualityGood      = "good"
+	qualityRetried   = "retried"
+	qualityHeld      = "held"
+	qualityEstimated = "estimated"
+)
+
+// stamped returns fields as a JSON payload. config.ReadingTimestamps
+// adds "time", when the sample was read, in RFC 3339 and the station
+// time zone, and config.ReadingQuality adds "quality".
+func stamped(fields map[string]float64, t time.Time, quality string) any {
+	if !config.ReadingTimestamps && !config.ReadingQuality {
+		return fields
+	}
+	payload := make(map[string]any, len(fields)+2)
+	for k, v := range fields {
+		payload[k] = v
+	}
+	if config.ReadingTimestamps {
+		payload["time"] = t.Format(time.RFC3339)
+	}
+	if config.ReadingQuality {
+		payload["quality"] = quality
+	}
+	return payload
+}
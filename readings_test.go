@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServeSensors(t *testing.T) {
+	g := &Gardener{}
+	at := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	g.readings.setSoil(42.5, 41, at)
+	g.readings.setEnv(map[string]float64{"temperature": 21.5, "humidity": 55, "pressure": 1013}, at.Add(time.Second))
+	g.readings.setPump(true)
+
+	rec := httptest.NewRecorder()
+	g.serveSensors(rec, httptest.NewRequest(http.MethodGet, "/api/sensors", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want %d", rec.Code, http.StatusOK)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("content type %q, want application/json", ct)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]any{
+		"soil":           42.5,
+		"soil_raw":       41.0,
+		"soil_time":      "2026-06-01T12:00:00Z",
+		"soil_temp":      0.0,
+		"soil_temp_time": "0001-01-01T00:00:00Z",
+		"temperature":    21.5,
+		"humidity":       55.0,
+		"pressure":       1013.0,
+		"env_time":       "2026-06-01T12:00:01Z",
+		"pump":           true,
+	} {
+		if got[key] != want {
+			t.Errorf("%s: %v, want %v", key, got[key], want)
+		}
+	}
+	if len(got) != 10 {
+		t.Errorf("%d keys, want 10: %v", len(got), got)
+	}
+}
+
+func TestServeSensorsMethod(t *testing.T) {
+	g := &Gardener{}
+	rec := httptest.NewRecorder()
+	g.serveSensors(rec, httptest.NewRequest(http.MethodPost, "/api/sensors", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+	if allow := rec.Header().Get("Allow"); allow != "GET" {
+		t.Errorf("Allow %q, want GET", allow)
+	}
+}
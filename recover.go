@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"github.com/rustyeddy/otto/messenger"
+)
+
+// maxPanicPayload is how much of a message is logged with a handler
+// panic.
+const maxPanicPayload = 256
+
+// panicRestartDelay keeps a goroutine that panics on every run from
+// spinning.
+const panicRestartDelay = time.Second
+
+// recoverPanic is deferred at the top of callbacks run by the device
+// library, like tickers and event handlers, so a panic is logged and
+// alerted rather than taking down the station. It does nothing when
+// config.RecoverPanics is off, letting the panic crash the process.
+func (g *Gardener) recoverPanic(name string) {
+	if !config.RecoverPanics {
+		return
+	}
+	if r := recover(); r != nil {
+		g.panicked(name, r)
+	}
+}
+
+func (g *Gardener) panicked(name string, r any) {
+	slog.Error("panic recovered", "goroutine", name, "panic", r, "stack", string(debug.Stack()))
+	g.Alert("panic", fmt.Sprintf("%s: %v", name, r))
+}
+
+// goSafe runs fn in a long-lived goroutine, restarting it if it
+// panics. The goroutine ends when fn returns normally.
+func (g *Gardener) goSafe(name string, fn func()) {
+	g.running.Add(1)
+	go func() {
+		defer g.running.Done()
+		for g.runSafe(name, fn) {
+			if !sleepOrStop(panicRestartDelay, g.ctx.Done()) {
+				return
+			}
+			slog.Warn("restarting goroutine", "goroutine", name)
+		}
+	}()
+}
+
+// runSafe calls fn and reports whether it panicked.
+func (g *Gardener) runSafe(name string, fn func()) (panicked bool) {
+	defer func() {
+		if !config.RecoverPanics {
+			return
+		}
+		if r := recover(); r != nil {
+			g.panicked(name, r)
+			panicked = true
+		}
+	}()
+	fn()
+	return false
+}
+
+// safeHandler wraps a subscription handler so a panic in it is logged
+// with the topic and payload, counted and turned into an error instead
+// of tearing down the messenger's callback and with it the
+// subscription.
+func (g *Gardener) safeHandler(h messenger.MsgHandler) messenger.MsgHandler {
+	return func(msg *messenger.Msg) (err error) {
+		defer func() {
+			if !config.RecoverPanics {
+				return
+			}
+			if r := recover(); r != nil {
+				payload := msg.Data
+				if len(payload) > maxPanicPayload {
+					payload = payload[:maxPanicPayload]
+				}
+				slog.Error("message handler panic recovered", "topic", msg.Topic, "payload", string(payload),
+					"panic", r, "stack", string(debug.Stack()))
+				g.metrics.Inc("gardener_handler_panics_total", "topic", msg.Topic)
+				g.Alert("panic", fmt.Sprintf("handler for %s: %v", msg.Topic, r))
+				err = fmt.Errorf("handler for %s panicked: %v", msg.Topic, r)
+			}
+		}()
+		return h(msg)
+	}
+}
+
+// subscribe subscribes h to topic behind safeHandler. Every
+// subscription goes through here, which also confirms the station's
+// own publishConfirmed messages as they come back.
+func (g *Gardener) subscribe(topic string, h messenger.MsgHandler) {
+	d := &g.deliveries
+	d.mu.Lock()
+	if d.subscribed == nil {
+		d.subscribed = make(map[string]bool)
+	}
+	d.subscribed[topic] = true
+	d.mu.Unlock()
+
+	safe := g.safeHandler(h)
+	g.mqttSubscribe(topic, func(msg *messenger.Msg) error {
+		g.confirm(msg)
+		return safe(msg)
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rustyeddy/otto/messenger"
+)
+
+func TestPanickingHandlerKeepsSubscription(t *testing.T) {
+	g, b := testGardener(t)
+	config.RecoverPanics = true
+	if err := g.Init(); err != nil {
+		t.Fatal(err)
+	}
+	g.Start()
+	t.Cleanup(g.Stop)
+
+	got := make(chan string, 10)
+	g.subscribe("c/test", func(msg *messenger.Msg) error {
+		if string(msg.Data) == "boom" {
+			panic("handler blew up")
+		}
+		got <- string(msg.Data)
+		return nil
+	})
+	for _, data := range []string{"first", "boom", "second", "boom", "third"} {
+		b.publish(t, "c/test", data)
+	}
+	for _, want := range []string{"first", "second", "third"} {
+		select {
+		case data := <-got:
+			if data != want {
+				t.Errorf("handled %q, want %q", data, want)
+			}
+		case <-time.After(testWait):
+			t.Fatalf("%q not handled after a panic", want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// retentionCheck is how often expired files are pruned from DataDir.
+const retentionCheck = 24 * time.Hour
+
+// pruneSummaries deletes the daily summaries in config.DataDir dated
+// more than config.SummaryRetentionDays before t, returning how many.
+// Only summaries are kept locally; raw readings go to MQTT and
+// InfluxDB, whose own retention applies to them.
+func pruneSummaries(t time.Time) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(config.DataDir, "summary-*.json"))
+	if err != nil {
+		return 0, err
+	}
+	cutoff := t.AddDate(0, 0, -config.SummaryRetentionDays).Format(time.DateOnly)
+	pruned := 0
+	for _, p := range paths {
+		date := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(p), "summary-"), ".json")
+		if _, err := time.Parse(time.DateOnly, date); err != nil || date >= cutoff {
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			return pruned, err
+		}
+		pruned++
+	}
+	return pruned, nil
+}
+
+func (g *Gardener) prune() {
+	n, err := pruneSummaries(now())
+	if err != nil {
+		slog.Error("summary pruning failed", "pruned", n, "error", err)
+		return
+	}
+	slog.Info("summaries pruned", "pruned", n, "retention_days", config.SummaryRetentionDays)
+}
+
+// startPruner prunes expired files on startup and then daily.
+func (g *Gardener) startPruner() {
+	if config.DataDir == "" || config.SummaryRetentionDays <= 0 {
+		return
+	}
+	g.prune()
+	g.every("pruner", retentionCheck, func(time.Time) {
+		g.prune()
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/rustyeddy/devices/relay"
+)
+
+// RGBLED is a common RGB LED with each channel on its own GPIO output,
+// used as an at-a-glance moisture indicator.
+type RGBLED struct {
+	name    string
+	r, g, b *relay.Relay
+
+	color string
+}
+
+// ledColors are the channels lit for each indicator color.
+var ledColors = map[string][3]bool{
+	"red":    {true, false, false},
+	"yellow": {true, true, false},
+	"green":  {false, true, false},
+}
+
+func newRGBLED(name string, r, g, b int) (*RGBLED, error) {
+	led := &RGBLED{name: name}
+	var err error
+	if led.r, err = relay.New(name+"-red", r); err != nil {
+		return nil, err
+	}
+	if led.g, err = relay.New(name+"-green", g); err != nil {
+		return nil, err
+	}
+	if led.b, err = relay.New(name+"-blue", b); err != nil {
+		return nil, err
+	}
+	return led, nil
+}
+
+func (l *RGBLED) Name() string {
+	return l.name
+}
+
+// Set lights the LED in the named color, doing nothing if it already
+// shows it.
+func (l *RGBLED) Set(color string) error {
+	if color == l.color {
+		return nil
+	}
+	ch := ledColors[color]
+	for i, r := range []*relay.Relay{l.r, l.g, l.b} {
+		var err error
+		if ch[i] {
+			err = r.On()
+		} else {
+			err = r.Off()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	l.color = color
+	return nil
+}
+
+// Off switches every channel off.
+func (l *RGBLED) Off() error {
+	for _, r := range []*relay.Relay{l.r, l.g, l.b} {
+		if err := r.Off(); err != nil {
+			return err
+		}
+	}
+	l.color = ""
+	return nil
+}
+
+// moistureColor maps a soil reading to red when dry, yellow in between
+// and green when moist.
+func moistureColor(v float64) string {
+	switch {
+	case v < config.LEDDry:
+		return "red"
+	case v < config.LEDMoist:
+		return "yellow"
+	default:
+		return "green"
+	}
+}
+
+func (g *Gardener) initLED() {
+	if config.LEDRed < 0 || config.LEDGreen < 0 || config.LEDBlue < 0 {
+		return
+	}
+	led, err := newRGBLED("led", config.LEDRed, config.LEDGreen, config.LEDBlue)
+	if err != nil {
+		panic(err)
+	}
+	g.addDevice(led)
+	g.addActuator("led", led.Off)
+	g.led = led
+}
+
+// showMoisture sets the LED color for a soil reading.
+func (g *Gardener) showMoisture(v float64) {
+	if g.led == nil {
+		return
+	}
+	if err := g.led.Set(moistureColor(v)); err != nil {
+		slog.Error("moisture led failed", "error", err)
+	}
+}
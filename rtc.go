@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+var (
+	// clockOffset is the difference between the RTC and the system
+	// clock, measured once during Init. It stays zero without an RTC.
+	clockOffset time.Duration
+
+	// location is the station's configured time zone.
+	location = time.Local
+)
+
+// now returns the station time in the station's time zone. It follows
+// the RTC when one is configured and the system clock otherwise. Use
+// it for timestamps and schedules rather than time.Now.
+func now() time.Time {
+	return time.Now().Add(clockOffset).In(location)
+}
+
+// DS3231 is a battery backed real-time clock on the I2C bus, used to
+// keep accurate time on stations without NTP.
+type DS3231 struct {
+	name string
+	bus  string
+	addr int
+}
+
+func newDS3231(name, bus string, addr int) *DS3231 {
+	return &DS3231{name: name, bus: bus, addr: addr}
+}
+
+func (d *DS3231) Name() string {
+	return d.name
+}
+
+func (d *DS3231) readRegs(reg byte, buf []byte) error {
+	return withBus(d.bus, func() error { return d.readRegsLocked(reg, buf) })
+}
+
+func (d *DS3231) readRegsLocked(reg byte, buf []byte) error {
+	f, err := openI2C(d.bus, d.addr)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write([]byte{reg}); err != nil {
+		return err
+	}
+	_, err = io.ReadFull(f, buf)
+	return err
+}
+
+// Get reads the current time from the clock. The DS3231 is assumed to
+// be kept in UTC and in 24 hour mode.
+func (d *DS3231) Get() (time.Time, error) {
+	buf := make([]byte, 7)
+	if err := d.readRegs(0x00, buf); err != nil {
+		return time.Time{}, err
+	}
+	return decodeDS3231(buf)
+}
+
+func bcd(b byte) int {
+	return int(b>>4)*10 + int(b&0x0f)
+}
+
+func decodeDS3231(buf []byte) (time.Time, error) {
+	sec := bcd(buf[0] & 0x7f)
+	min := bcd(buf[1] & 0x7f)
+	hour := bcd(buf[2] & 0x3f)
+	day := bcd(buf[4] & 0x3f)
+	month := bcd(buf[5] & 0x1f)
+	year := 2000 + bcd(buf[6])
+	if buf[5]&0x80 != 0 {
+		year += 100
+	}
+
+	if month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || min > 59 || sec > 59 {
+		return time.Time{}, fmt.Errorf("ds3231 returned invalid time % x", buf)
+	}
+	return time.Date(year, time.Month(month), day, hour, min, sec, 0, time.UTC), nil
+}
+
+func (g *Gardener) initRTC() {
+	if config.RTCBus == "" {
+		slog.Info("time source", "source", "system")
+		return
+	}
+
+	rtc := newDS3231("rtc", config.RTCBus, config.RTCAddr)
+	t, err := rtc.Get()
+	if err != nil {
+		slog.Warn("rtc read failed, using system time", "bus", config.RTCBus, "error", err)
+		slog.Info("time source", "source", "system")
+		return
+	}
+	g.addDevice(rtc)
+	clockOffset = time.Until(t)
+	slog.Info("time source", "source", "rtc", "time", t, "offset", clockOffset)
+}
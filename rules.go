@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rustyeddy/otto/messenger"
+	"gopkg.in/yaml.v3"
+)
+
+// ruleMetrics are the readings a rule condition can name.
+var ruleMetrics = map[string]func(Readings) (float64, bool){
+	"soil":        func(r Readings) (float64, bool) { return r.Soil, !r.SoilTime.IsZero() },
+	"soiltemp":    func(r Readings) (float64, bool) { return r.SoilTemp, !r.SoilTempTime.IsZero() },
+	"temperature": func(r Readings) (float64, bool) { return r.Temperature, !r.EnvTime.IsZero() },
+	"humidity":    func(r Readings) (float64, bool) { return r.Humidity, !r.EnvTime.IsZero() },
+	"pressure":    func(r Readings) (float64, bool) { return r.Pressure, !r.EnvTime.IsZero() },
+}
+
+var ruleOps = map[string]func(a, b float64) bool{
+	"<":  func(a, b float64) bool { return a < b },
+	"<=": func(a, b float64) bool { return a <= b },
+	">":  func(a, b float64) bool { return a > b },
+	">=": func(a, b float64) bool { return a >= b },
+	"==": func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+}
+
+// Rule is one automation rule from the rules file, such as when
+// "soil < 30 for 5m" then "pump on 30s". The condition compares a
+// reading with a number and may require it to hold for a while. The
+// action sends on or off to a controllable device, and an on may be
+// limited to a duration. A rule fires once each time its condition
+// becomes true.
+type Rule struct {
+	When string `yaml:"when"`
+	Then string `yaml:"then"`
+
+	metric string
+	op     string
+	value  float64
+	hold   time.Duration
+
+	device string
+	state  string
+	run    time.Duration
+
+	since time.Time
+	fired bool
+
+	// off is the pending timed off of the last action, guarded by g.mu.
+	off *time.Timer
+}
+
+// parse checks the rule and compiles its condition and action.
+func (r *Rule) parse() error {
+	f := strings.Fields(r.When)
+	if len(f) != 3 && len(f) != 5 {
+		return fmt.Errorf("when %q: want \"<metric> <op> <value> [for <duration>]\"", r.When)
+	}
+	if _, ok := ruleMetrics[f[0]]; !ok {
+		return fmt.Errorf("when %q: unknown metric %q", r.When, f[0])
+	}
+	if _, ok := ruleOps[f[1]]; !ok {
+		return fmt.Errorf("when %q: unknown operator %q", r.When, f[1])
+	}
+	v, err := strconv.ParseFloat(f[2], 64)
+	if err != nil {
+		return fmt.Errorf("when %q: bad value %q", r.When, f[2])
+	}
+	r.metric, r.op, r.value = f[0], f[1], v
+	if len(f) == 5 {
+		if f[3] != "for" {
+			return fmt.Errorf("when %q: expected \"for\", got %q", r.When, f[3])
+		}
+		if r.hold, err = time.ParseDuration(f[4]); err != nil {
+			return fmt.Errorf("when %q: %w", r.When, err)
+		}
+	}
+
+	f = strings.Fields(r.Then)
+	if len(f) < 2 || len(f) > 3 {
+		return fmt.Errorf("then %q: want \"<device> on|off [<duration>]\"", r.Then)
+	}
+	r.device, r.state = f[0], f[1]
+	if r.state != "on" && r.state != "off" {
+		return fmt.Errorf("then %q: unknown state %q", r.Then, r.state)
+	}
+	if len(f) == 3 {
+		if r.state != "on" {
+			return fmt.Errorf("then %q: only on takes a duration", r.Then)
+		}
+		if r.run, err = time.ParseDuration(f[2]); err != nil {
+			return fmt.Errorf("then %q: %w", r.Then, err)
+		}
+	}
+	return nil
+}
+
+// loadRules reads and checks the rules file.
+func loadRules(path string) ([]*Rule, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []*Rule
+	if err := yaml.Unmarshal(buf, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, r := range rules {
+		if err := r.parse(); err != nil {
+			return nil, fmt.Errorf("%s: rule %d: %w", path, i+1, err)
+		}
+	}
+	return rules, nil
+}
+
+// initRules loads config.RulesFile, panicking on a malformed rule or
+// one that acts on a device that cannot be controlled.
+func (g *Gardener) initRules() {
+	if config.RulesFile == "" {
+		return
+	}
+	rules, err := loadRules(config.RulesFile)
+	if err != nil {
+		panic(err)
+	}
+	for _, r := range rules {
+		if _, ok := g.controls[r.device]; !ok {
+			panic(fmt.Errorf("%s: rule %q: device %q is not controllable", config.RulesFile, r.Then, r.device))
+		}
+	}
+	g.rules = rules
+	slog.Info("rules loaded", "file", config.RulesFile, "rules", len(rules))
+}
+
+// startRules evaluates the rules against the latest readings every
+// config.RulesInterval.
+func (g *Gardener) startRules() {
+	if len(g.rules) == 0 {
+		return
+	}
+	g.every("rules", config.RulesInterval, func(t time.Time) {
+		g.evalRules(g.readings.Snapshot(), t)
+	})
+}
+
+func (g *Gardener) evalRules(r Readings, t time.Time) {
+	for _, rule := range g.rules {
+		v, ok := ruleMetrics[rule.metric](r)
+		if !ok || !ruleOps[rule.op](v, rule.value) {
+			rule.since, rule.fired = time.Time{}, false
+			continue
+		}
+		if rule.since.IsZero() {
+			rule.since = t
+		}
+		if rule.fired || t.Sub(rule.since) < rule.hold {
+			continue
+		}
+		if g.water.Suppressed() || g.emergencyStopped() {
+			continue
+		}
+		rule.fired = true
+		slog.Info("rule fired", "when", rule.When, "then", rule.Then, "value", v)
+		g.ruleAction(rule)
+	}
+}
+
+// ruleAction sends the rule's command through the device's control
+// handler, the same path as c/<device>/set.
+func (g *Gardener) ruleAction(rule *Rule) {
+	h := g.controls[rule.device]
+	topic := "c/" + rule.device + "/set"
+	if err := h(&messenger.Msg{Topic: topic, Data: []byte(rule.state)}); err != nil {
+		slog.Error("rule action failed", "then", rule.Then, "error", err)
+		return
+	}
+	if rule.run > 0 {
+		g.mu.Lock()
+		if rule.off != nil {
+			rule.off.Stop()
+		}
+		seq := g.commands[rule.device]
+		rule.off = time.AfterFunc(rule.run, func() { g.ruleOff(rule, seq) })
+		g.mu.Unlock()
+	}
+}
+
+// ruleOff ends a rule's timed on, unless the device has been
+// commanded since seq, by the rule firing again or anything else, in
+// which case that later command stands.
+func (g *Gardener) ruleOff(rule *Rule, seq uint64) {
+	defer g.recoverPanic("rules")
+	g.mu.Lock()
+	superseded := g.commands[rule.device] != seq
+	if !superseded {
+		rule.off = nil
+	}
+	g.mu.Unlock()
+	if superseded {
+		slog.Info("rule off skipped, device commanded since", "then", rule.Then)
+		return
+	}
+	topic := "c/" + rule.device + "/set"
+	if err := g.controls[rule.device](&messenger.Msg{Topic: topic, Data: []byte("off")}); err != nil {
+		slog.Error("rule action failed", "then", rule.Then, "error", err)
+	}
+}
+
+// stopRules cancels the pending timed offs of the rules' actions, on
+// Stop and on an emergency stop, which switch the devices off
+// themselves.
+func (g *Gardener) stopRules() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, r := range g.rules {
+		if r.off != nil {
+			r.off.Stop()
+			r.off = nil
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/rustyeddy/otto/messenger"
+)
+
+func TestRuleTimedOff(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		after   func(g *Gardener)
+		wants   []string
+		rearmed bool
+	}{
+		{"off after the run", func(*Gardener) {}, []string{"on", "off"}, false},
+		{"superseded by another command", func(g *Gardener) {
+			g.controls["valve"](&messenger.Msg{Topic: "c/valve/set", Data: []byte("on")})
+		}, []string{"on", "on"}, false},
+		{"fired again", func(g *Gardener) { g.ruleAction(g.rules[0]) }, []string{"on", "on"}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g, got := testRules(t)
+			r := g.rules[0]
+			g.ruleAction(r)
+			seq, armed := g.commands["valve"], r.off
+
+			tc.after(g)
+			g.ruleOff(r, seq)
+			if !slices.Equal(*got, tc.wants) {
+				t.Errorf("commands %q, want %q", *got, tc.wants)
+			}
+			if rearmed := r.off != nil && r.off != armed; rearmed != tc.rearmed {
+				t.Errorf("new timed off armed %v, want %v", rearmed, tc.rearmed)
+			}
+		})
+	}
+}
+
+func TestStopRulesCancelsTimedOff(t *testing.T) {
+	g, _ := testRules(t)
+	r := g.rules[0]
+	g.ruleAction(r)
+	armed := r.off
+	g.stopRules()
+	if r.off != nil || armed.Stop() {
+		t.Error("timed off still pending after stopRules")
+	}
+}
+
+// testRules returns a station with one rule turning a valve on for an
+// hour, and the commands the valve has been sent.
+func testRules(t *testing.T) (*Gardener, *[]string) {
+	t.Helper()
+	var got []string
+	g := &Gardener{}
+	g.Control("valve", func(msg *messenger.Msg) error {
+		got = append(got, string(msg.Data))
+		return nil
+	})
+	r := &Rule{When: "soil < 30", Then: "valve on 1h"}
+	if err := r.parse(); err != nil {
+		t.Fatal(err)
+	}
+	g.rules = []*Rule{r}
+	t.Cleanup(g.stopRules)
+	return g, &got
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ThresholdRange applies Threshold between Start and End, both offsets
+// from local midnight. A range whose End is before its Start wraps
+// past midnight.
+type ThresholdRange struct {
+	Start     time.Duration
+	End       time.Duration
+	Threshold float64
+}
+
+func (r ThresholdRange) contains(tod time.Duration) bool {
+	if r.Start <= r.End {
+		return tod >= r.Start && tod < r.End
+	}
+	return tod >= r.Start || tod < r.End
+}
+
+// ThresholdSchedule maps times of day to watering thresholds. As a
+// flag it is written as a comma separated list of HH:MM-HH:MM=value,
+// e.g. "11:00-16:00=20,22:00-05:00=25".
+type ThresholdSchedule []ThresholdRange
+
+// At returns the threshold of the first range containing t, or def
+// when t falls outside every range.
+func (s ThresholdSchedule) At(t time.Time, def float64) float64 {
+	tod := timeOfDay(t)
+	for _, r := range s {
+		if r.contains(tod) {
+			return r.Threshold
+		}
+	}
+	return def
+}
+
+func (s *ThresholdSchedule) String() string {
+	if s == nil {
+		return ""
+	}
+	var parts []string
+	for _, r := range *s {
+		parts = append(parts, fmt.Sprintf("%s-%s=%g", fmtTOD(r.Start), fmtTOD(r.End), r.Threshold))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (s *ThresholdSchedule) Set(v string) error {
+	var sched ThresholdSchedule
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		span, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("threshold range %q: missing =value", part)
+		}
+		from, to, ok := strings.Cut(span, "-")
+		if !ok {
+			return fmt.Errorf("threshold range %q: expected HH:MM-HH:MM", part)
+		}
+
+		var r ThresholdRange
+		var err error
+		if r.Start, err = parseTOD(from); err != nil {
+			return fmt.Errorf("threshold range %q: %w", part, err)
+		}
+		if r.End, err = parseTOD(to); err != nil {
+			return fmt.Errorf("threshold range %q: %w", part, err)
+		}
+		if r.Threshold, err = strconv.ParseFloat(strings.TrimSpace(val), 64); err != nil {
+			return fmt.Errorf("threshold range %q: %w", part, err)
+		}
+		sched = append(sched, r)
+	}
+	*s = sched
+	return nil
+}
+
+// timeOfDay returns the wall clock time of t as an offset from
+// midnight. It is not t less midnight, which is an hour out on the days
+// the clocks change.
+func timeOfDay(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+}
+
+// onDay returns the time tod, an offset from midnight, reads on the
+// wall clock on t's day.
+func onDay(t time.Time, tod time.Duration) time.Time {
+	h, m := int(tod/time.Hour), int(tod%time.Hour/time.Minute)
+	return time.Date(t.Year(), t.Month(), t.Day(), h, m, 0, int(tod%time.Minute), t.Location())
+}
+
+// parseTOD parses HH:MM into an offset from midnight.
+func parseTOD(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("bad time of day %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func fmtTOD(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // the DST tests must not depend on the host's zoneinfo
+)
+
+// TestThresholdScheduleDST checks the schedule follows the wall clock
+// on the days the clocks change, when a day is 23 or 25 hours long.
+func TestThresholdScheduleDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s ThresholdSchedule
+	if err := s.Set("10:00-11:00=20,22:00-05:00=25"); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		t    time.Time
+		want float64
+	}{
+		{time.Date(2026, 3, 8, 10, 0, 0, 0, loc), 20},   // spring forward
+		{time.Date(2026, 3, 8, 9, 30, 0, 0, loc), 30},   // only 8h30m after midnight
+		{time.Date(2026, 11, 1, 10, 30, 0, 0, loc), 20}, // fall back
+		{time.Date(2026, 11, 1, 11, 0, 0, 0, loc), 30},
+		{time.Date(2026, 11, 1, 4, 59, 0, 0, loc), 25},
+		{time.Date(2026, 11, 1, 5, 0, 0, 0, loc), 30},
+	} {
+		if got := s.At(tc.t, 30); got != tc.want {
+			t.Errorf("At(%s) = %g, want %g", tc.t.Format(time.DateTime), got, tc.want)
+		}
+	}
+}
+
+func TestNextDeepWaterDST(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config.DeepWaterAt = "05:00"
+	config.DeepWaterEvery = 72 * time.Hour
+
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 3, 8, 1, 0, 0, 0, loc)
+	want := time.Date(2026, 3, 8, 5, 0, 0, 0, loc)
+	if got := nextDeepWater(time.Time{}, start); !got.Equal(want) {
+		t.Errorf("next deep soak = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// addSensor records a sensor's read-and-publish callback so it can be
+// run outside its ticker, as the final reading does.
+func (g *Gardener) addSensor(name string, read func(time.Time)) {
+	if g.sensors == nil {
+		g.sensors = make(map[string]func(time.Time))
+	}
+	g.sensors[name] = read
+}
+
+// publishFinal takes and publishes one last reading from every sensor
+// and then the offline status, so the last point on a chart is real
+// rather than stale. The readings bypass the water controller.
+func (g *Gardener) publishFinal() error {
+	t := now()
+	for name, read := range g.sensors {
+		slog.Info("final reading", "device", name)
+		read(t)
+	}
+	g.publish("e/status", []byte("offline"))
+	return nil
+}
+
+// shutdownPhase is one step of shutdown. A phase that does not finish
+// within timeout, config.ShutdownTimeout if zero, is logged as stuck
+// and left behind so the phases after it still run.
+type shutdownPhase struct {
+	name    string
+	timeout time.Duration
+	run     func() error
+}
+
+// runPhases runs the shutdown phases in order, logging when each
+// starts and how it ended, so a hung shutdown shows which phase is
+// stuck.
+func (g *Gardener) runPhases(phases []shutdownPhase) {
+	start := time.Now()
+	for i, p := range phases {
+		timeout := p.timeout
+		if timeout <= 0 {
+			timeout = config.ShutdownTimeout
+		}
+		slog.Info("shutdown phase started", "phase", p.name, "step", i+1, "steps", len(phases), "timeout", timeout)
+		began := time.Now()
+		done := make(chan error, 1)
+		go func() {
+			// err stays set if the phase panics and is recovered.
+			done <- func() (err error) {
+				err = errors.New("panicked")
+				defer g.recoverPanic("shutdown-" + p.name)
+				return p.run()
+			}()
+		}()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				slog.Error("shutdown phase failed", "phase", p.name, "duration", time.Since(began), "error", err)
+			} else {
+				slog.Info("shutdown phase complete", "phase", p.name, "duration", time.Since(began))
+			}
+		case <-time.After(timeout):
+			slog.Warn("shutdown phase timed out", "phase", p.name, "timeout", timeout)
+		}
+	}
+	slog.Info("shutdown complete", "duration", time.Since(start))
+}
+
+// shutdownPhases returns the phases Stop runs.
+func (g *Gardener) shutdownPhases() []shutdownPhase {
+	var phases []shutdownPhase
+	if config.PublishOnShutdown {
+		phases = append(phases, shutdownPhase{name: "final-readings", run: g.publishFinal})
+	}
+	// Never exit with the pump energized, even mid pulse or soak. The
+	// step delays are allowed on top of the timeout.
+	steps := time.Duration(len(g.actuators)) * config.ShutdownStepDelay
+	phases = append(phases, shutdownPhase{
+		name:    "actuators-off",
+		timeout: config.ShutdownTimeout + steps,
+		run: func() error {
+			g.actuatorsOff(config.ShutdownStepDelay)
+			return nil
+		},
+	})
+	if g.influx != nil {
+		phases = append(phases, shutdownPhase{name: "influx-flush", run: g.influx.Flush})
+	}
+	phases = append(phases, shutdownPhase{name: "save-state", run: func() error {
+		g.saveState()
+		return nil
+	}})
+	phases = append(phases, shutdownPhase{name: "display-clear", run: func() error {
+		g.display.Clear()
+		return g.display.Draw()
+	}})
+	if g.pubq != nil {
+		phases = append(phases, shutdownPhase{name: "publish-drain", run: func() error {
+			defer g.pubq.close()
+			return g.pubq.drain()
+		}})
+	}
+	if g.health.connected.Load() {
+		phases = append(phases, shutdownPhase{name: "mqtt-disconnect", run: g.disconnectBroker})
+	}
+	if g.Server != nil {
+		phases = append(phases, shutdownPhase{name: "http-shutdown", run: func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+			defer cancel()
+			return g.Server.Server.Shutdown(ctx)
+		}})
+	}
+	// The background goroutines were cancelled before the phases ran;
+	// wait them out so none is still running when Stop returns.
+	phases = append(phases, shutdownPhase{name: "goroutines", run: func() error {
+		g.running.Wait()
+		return nil
+	}})
+	return phases
+}
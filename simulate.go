@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// maxSimulateHorizon bounds how far ahead /api/simulate looks.
+	maxSimulateHorizon = 7 * 24 * time.Hour
+
+	// maxSimulateSteps bounds the work one request can ask for.
+	maxSimulateSteps = 100000
+)
+
+// SoilModel is the simple soil decay model the simulation runs: Drift
+// points of moisture per hour, in practice negative as the soil dries,
+// plus Water per hour while the pump runs.
+type SoilModel struct {
+	Drift float64 `json:"drift"`
+	Water float64 `json:"water"`
+}
+
+// soilModel returns the default model, the emulator's own under -mock
+// and otherwise one that dries a point an hour and wets a point a
+// minute.
+func (g *Gardener) soilModel() SoilModel {
+	if g.emu != nil {
+		s := g.emu.State()
+		perHour := float64(time.Hour / emulatorTick)
+		return SoilModel{Drift: s.Drift * perHour, Water: s.Water * perHour}
+	}
+	return SoilModel{Drift: -1, Water: 60}
+}
+
+// SimEvent is a predicted pump event. Reason names the limit that
+// cut a watering short.
+type SimEvent struct {
+	Time      time.Time `json:"time"`
+	Pump      string    `json:"pump"`
+	Soil      float64   `json:"soil"`
+	Threshold float64   `json:"threshold"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// Simulation is the predicted timeline served on /api/simulate.
+type Simulation struct {
+	Start   time.Time     `json:"start"`
+	End     time.Time     `json:"end"`
+	Step    time.Duration `json:"step"`
+	Soil    float64       `json:"soil"`
+	Model   SoilModel     `json:"model"`
+	Events  []SimEvent    `json:"events"`
+	EndSoil float64       `json:"end_soil"`
+	Pumping time.Duration `json:"pumping"`
+}
+
+// simulate runs the controller forward from soil at start for horizon
+// in steps of step, feeding each step in as a soil reading. It works on
+// a forecast copy, so the mode, profile, confirmation count and limits
+// carry on from where the station stands and the station itself is left
+// alone. The limits a timer enforces are applied as the steps pass
+// them; the rules and deep soaks are left out.
+func (w *WaterController) simulate(start time.Time, horizon, step time.Duration, soil float64, m SoilModel) Simulation {
+	sim := Simulation{Start: start, End: start.Add(horizon), Step: step, Soil: soil, Model: m, Events: []SimEvent{}}
+	var maxRun time.Duration
+	if w.g.pump != nil {
+		maxRun = time.Duration(config.PumpMaxRunSeconds) * time.Second
+	}
+
+	f := w.forecast()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	hours := step.Hours()
+	for t := start; t.Before(sim.End); t = t.Add(step) {
+		if f.watering {
+			reason := ""
+			switch {
+			case !f.limitAt.IsZero() && !t.Before(f.limitAt):
+				reason = "watering limit"
+			case maxRun > 0 && t.Sub(f.startedAt) >= maxRun:
+				reason = "pump max runtime"
+			}
+			if reason != "" {
+				f.cutShort(t)
+				sim.Events = append(sim.Events, SimEvent{Time: t, Pump: "off", Soil: soil, Reason: reason})
+			}
+		}
+		if changed, threshold := f.update(soil, t); changed {
+			ev := SimEvent{Time: t, Pump: "off", Soil: soil, Threshold: threshold}
+			if f.watering {
+				ev.Pump = "on"
+			}
+			sim.Events = append(sim.Events, ev)
+		}
+		soil += m.Drift * hours
+		if f.watering {
+			soil += m.Water * hours
+			sim.Pumping += step
+		}
+		soil = min(max(soil, 0), 100)
+	}
+	sim.EndSoil = soil
+	return sim
+}
+
+// serveSimulate answers "what would the station do over the next
+// hours?" from the current soil reading and the controller's state. The query
+// parameters hours (24), step (1m), soil, drift and water override the
+// defaults.
+func (g *Gardener) serveSimulate(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	horizon := 24 * time.Hour
+	step := time.Minute
+	soil := g.readings.Snapshot().Soil
+	m := g.soilModel()
+
+	var err error
+	if v := q.Get("hours"); v != "" {
+		var h float64
+		if h, err = strconv.ParseFloat(v, 64); err == nil {
+			horizon = time.Duration(h * float64(time.Hour))
+		}
+	}
+	if v := q.Get("step"); err == nil && v != "" {
+		step, err = time.ParseDuration(v)
+	}
+	for name, p := range map[string]*float64{"soil": &soil, "drift": &m.Drift, "water": &m.Water} {
+		if v := q.Get(name); err == nil && v != "" {
+			*p, err = strconv.ParseFloat(v, 64)
+		}
+	}
+	if err == nil && (horizon <= 0 || horizon > maxSimulateHorizon) {
+		err = fmt.Errorf("hours must be between 0 and %v", maxSimulateHorizon.Hours())
+	}
+	if err == nil && (step <= 0 || horizon/step > maxSimulateSteps) {
+		err = fmt.Errorf("step %s is too small for %s", step, horizon)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sim := g.water.simulate(now(), horizon, step, soil, m)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(sim); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSimulateFollowsController(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		setup   func(w *WaterController)
+		soil    float64
+		wants   []string
+		pumping time.Duration
+	}{
+		{"wet soil", nil, 40, nil, 0},
+		{"confirms before watering", nil, 25, []string{"2m0s on"}, 58 * time.Minute},
+		{"watering limit", func(*WaterController) { config.MaxWaterSeconds = 300 }, 25,
+			[]string{"2m0s on", "7m0s off watering limit", "39m0s on", "44m0s off watering limit"}, 10 * time.Minute},
+		{"schedule on the normal profile", func(*WaterController) {
+			config.ThresholdSchedule.Set("11:00-13:00=20")
+		}, 25, nil, 0},
+		{"schedule skipped off the normal profile", func(w *WaterController) {
+			config.ThresholdSchedule.Set("11:00-13:00=20")
+			w.SetProfile(&Profile{Name: profileVacation, Low: 30, High: 50})
+		}, 25, []string{"2m0s on"}, 58 * time.Minute},
+		{"auto watering off", func(*WaterController) { config.AutoWater = false }, 25, nil, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := testController(t)
+			if tc.setup != nil {
+				tc.setup(w)
+			}
+			start := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+			sim := w.simulate(start, time.Hour, time.Minute, tc.soil, SoilModel{})
+			var got []string
+			for _, ev := range sim.Events {
+				got = append(got, strings.TrimSpace(fmt.Sprintf("%s %s %s", ev.Time.Sub(start), ev.Pump, ev.Reason)))
+			}
+			if !slices.Equal(got, tc.wants) {
+				t.Errorf("events %q, want %q", got, tc.wants)
+			}
+			if sim.Pumping != tc.pumping {
+				t.Errorf("pumping %s, want %s", sim.Pumping, tc.pumping)
+			}
+			if w.watering || len(pumpCommands(w)) > 0 {
+				t.Errorf("simulation changed the controller: watering %v, pump commands %q", w.watering, pumpCommands(w))
+			}
+		})
+	}
+}
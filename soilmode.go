@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rustyeddy/devices/vh400"
+)
+
+// Soil sensor conversion modes. A VH400 puts out a voltage that is run
+// through its moisture curve; simpler capacitive sensors put out the
+// percentage itself, which is passed through as read.
+const (
+	soilModeVH400   = "vh400"
+	soilModePercent = "percent"
+)
+
+// soilSensors are the soil sensors that can be given a mode.
+var soilSensors = []string{"soil"}
+
+// soilModes parses config.SoilModes into the mode of each soil sensor,
+// vh400 unless set. Each sensor must be given exactly one mode.
+func soilModes() (map[string]string, error) {
+	modes := make(map[string]string, len(soilSensors))
+	for _, name := range soilSensors {
+		modes[name] = soilModeVH400
+	}
+	set := make(map[string]string)
+	for _, s := range config.SoilModes {
+		name, mode, ok := strings.Cut(s, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("soil mode %q: expected name=mode", s)
+		}
+		if _, known := modes[name]; !known {
+			return nil, fmt.Errorf("soil mode %q: unknown soil sensor %q", s, name)
+		}
+		if mode != soilModeVH400 && mode != soilModePercent {
+			return nil, fmt.Errorf("soil mode %q: want %s or %s", s, soilModeVH400, soilModePercent)
+		}
+		if prev, ok := set[name]; ok && prev != mode {
+			return nil, fmt.Errorf("soil sensor %q is given both %s and %s: choose one", name, prev, mode)
+		}
+		set[name] = mode
+		modes[name] = mode
+	}
+	return modes, nil
+}
+
+// validateSoilModes checks config.SoilModes.
+func validateSoilModes() error {
+	_, err := soilModes()
+	return err
+}
+
+// soilMode returns the conversion mode of the named soil sensor.
+func soilMode(name string) string {
+	// Checked when the flags were parsed.
+	modes, _ := soilModes()
+	return modes[name]
+}
+
+// readSoil reads a soil sensor in mode. Callers see a moisture
+// percentage either way.
+func readSoil(mode string, s *vh400.VH400) (float64, error) {
+	if mode == soilModePercent {
+		return s.Pin.Get()
+	}
+	return s.Get()
+}
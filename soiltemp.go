@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rustyeddy/otto/messenger"
+)
+
+// soilTempMaxAge is how old a soil temperature may be and still be
+// used for compensation.
+const soilTempMaxAge = 5 * time.Minute
+
+// compensateSoil corrects a soil moisture reading for soil temperature
+// drift, adding SoilTempCoeff per degree below SoilTempRef. The second
+// result is false, and raw is returned unchanged, when compensation is
+// disabled or there is no recent soil temperature.
+func (g *Gardener) compensateSoil(raw float64) (float64, bool) {
+	if config.SoilTempCoeff == 0 {
+		return raw, false
+	}
+	r := g.readings.Snapshot()
+	if r.SoilTempTime.IsZero() || now().Sub(r.SoilTempTime) > soilTempMaxAge {
+		return raw, false
+	}
+	return raw + config.SoilTempCoeff*(config.SoilTempRef-r.SoilTemp), true
+}
+
+// initSoilTemp subscribes to an externally published soil temperature.
+func (g *Gardener) initSoilTemp() {
+	if config.SoilTempTopic == "" {
+		return
+	}
+	g.subscribe(config.SoilTempTopic, g.soilTempHandler)
+}
+
+func (g *Gardener) soilTempHandler(msg *messenger.Msg) error {
+	v, err := strconv.ParseFloat(strings.TrimSpace(string(msg.Data)), 64)
+	if err != nil {
+		return fmt.Errorf("bad soil temperature %q: %w", msg.Data, err)
+	}
+	slog.Debug("soil temperature", "topic", msg.Topic, "value", v)
+	g.readings.setSoilTemp(v, now())
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/rustyeddy/otto/messenger"
+)
+
+// stateGetTopic asks for StateDump to be published on d/state/dump.
+const stateGetTopic = "c/state/get"
+
+// publishState publishes every current reading and the pump state as
+// one JSON document on d/state. On bandwidth constrained links this
+// replaces a round-trip per topic with one per interval.
+func (g *Gardener) publishState() {
+	jbuf, err := json.Marshal(g.readings.Snapshot())
+	if err != nil {
+		slog.Error("state marshal failed", "error", err)
+		return
+	}
+	g.publish("d/state", jbuf)
+}
+
+func (g *Gardener) startStatePublisher() {
+	if !config.PublishState {
+		return
+	}
+	g.every("state", config.StateInterval, func(time.Time) {
+		g.publishState()
+	})
+}
+
+// StateDump is everything a newly connected consumer needs: the
+// readings, how the pump is being controlled, the watering settings and
+// the health of every device.
+type StateDump struct {
+	Station  string                `json:"station"`
+	Zone     string                `json:"zone"`
+	Time     time.Time             `json:"time"`
+	Readings Readings              `json:"readings"`
+	Mode     string                `json:"mode"`
+	Auto     bool                  `json:"auto_water"`
+	Low      float64               `json:"low_threshold"`
+	High     float64               `json:"high_threshold"`
+	Health   map[string]DeviceDiag `json:"health"`
+
+	Calibration calibrations `json:"calibration,omitempty"`
+}
+
+// handleStateGet publishes a StateDump on d/state/dump for any message
+// on c/state/get, at most once per config.StateGetInterval.
+func (g *Gardener) handleStateGet(msg *messenger.Msg) error {
+	g.mu.Lock()
+	t := now()
+	if t.Sub(g.lastDump) < config.StateGetInterval {
+		g.mu.Unlock()
+		slog.Warn("state request rate limited", "topic", msg.Topic)
+		return nil
+	}
+	g.lastDump = t
+	g.mu.Unlock()
+
+	low, high := g.water.Thresholds()
+	dump := StateDump{
+		Station:  config.StationName,
+		Zone:     config.Zone,
+		Time:     t,
+		Readings: g.readings.Snapshot(),
+		Mode:     g.water.Mode(),
+		Auto:     config.AutoWater,
+		Low:      low,
+		High:     high,
+		Health:   g.diag.Snapshot(),
+
+		Calibration: config.Calibrate,
+	}
+	jbuf, err := json.Marshal(dump)
+	if err != nil {
+		slog.Error("state dump marshal failed", "error", err)
+		return nil
+	}
+	g.publish("d/state/dump", jbuf)
+	return nil
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// summaryDays is how many past daily summaries are kept in memory.
+const summaryDays = 31
+
+// SensorStats summarizes one sensor's readings over a day. Quantiles
+// holds the estimates of config.Quantiles, e.g. "p90", which are
+// streamed and so start afresh when a saved summary is restored.
+type SensorStats struct {
+	Min       float64            `json:"min"`
+	Max       float64            `json:"max"`
+	Avg       float64            `json:"avg"`
+	Count     int                `json:"count"`
+	Quantiles map[string]float64 `json:"quantiles,omitempty"`
+
+	sum float64
+	est []*p2
+}
+
+func (s *SensorStats) observe(v float64) {
+	if s.est == nil && len(config.Quantiles) > 0 {
+		for _, q := range config.Quantiles {
+			s.est = append(s.est, newP2(q))
+		}
+	}
+	if len(s.est) > 0 {
+		if s.Quantiles == nil {
+			s.Quantiles = make(map[string]float64, len(s.est))
+		}
+		for _, e := range s.est {
+			e.observe(v)
+			s.Quantiles[quantileName(e.p)] = e.value()
+		}
+	}
+	if s.Count == 0 || v < s.Min {
+		s.Min = v
+	}
+	if s.Count == 0 || v > s.Max {
+		s.Max = v
+	}
+	s.sum += v
+	s.Count++
+	s.Avg = s.sum / float64(s.Count)
+}
+
+// DailySummary is the journal of one day at the station.
+type DailySummary struct {
+	Date               string                  `json:"date"`
+	Sensors            map[string]*SensorStats `json:"sensors"`
+	PumpRuntimeSeconds float64                 `json:"pump_runtime_seconds"`
+	Waterings          int                     `json:"waterings"`
+	Alerts             []string                `json:"alerts"`
+}
+
+func newDailySummary(date string) *DailySummary {
+	return &DailySummary{Date: date, Sensors: make(map[string]*SensorStats), Alerts: []string{}}
+}
+
+// summarizer accumulates today's DailySummary and keeps recent days.
+type summarizer struct {
+	mu   sync.Mutex
+	cur  *DailySummary
+	past map[string]*DailySummary
+}
+
+func newSummarizer() *summarizer {
+	return &summarizer{
+		cur:  newDailySummary(now().Format(time.DateOnly)),
+		past: make(map[string]*DailySummary),
+	}
+}
+
+// Observe records a sensor reading.
+func (s *summarizer) Observe(name string, v float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.cur.Sensors[name]
+	if !ok {
+		st = &SensorStats{}
+		s.cur.Sensors[name] = st
+	}
+	st.observe(v)
+}
+
+// Watering counts one watering.
+func (s *summarizer) Watering() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cur.Waterings++
+}
+
+// PumpRan adds to the day's pump runtime.
+func (s *summarizer) PumpRan(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cur.PumpRuntimeSeconds += d.Seconds()
+}
+
+// Alert records a raised alert.
+func (s *summarizer) Alert(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cur.Alerts = append(s.cur.Alerts, name)
+}
+
+// current returns a copy of today's summary so far.
+func (s *summarizer) current() *DailySummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := *s.cur
+	c.Sensors = make(map[string]*SensorStats, len(s.cur.Sensors))
+	for name, st := range s.cur.Sensors {
+		cp := *st
+		cp.Quantiles = maps.Clone(st.Quantiles)
+		cp.est = nil
+		c.Sensors[name] = &cp
+	}
+	c.Alerts = append([]string{}, s.cur.Alerts...)
+	return &c
+}
+
+// restore continues a saved summary if it is for today.
+func (s *summarizer) restore(sum *DailySummary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sum.Date != s.cur.Date {
+		return
+	}
+	if sum.Sensors == nil {
+		sum.Sensors = make(map[string]*SensorStats)
+	}
+	for _, st := range sum.Sensors {
+		st.sum = st.Avg * float64(st.Count)
+	}
+	if sum.Alerts == nil {
+		sum.Alerts = []string{}
+	}
+	s.cur = sum
+}
+
+// roll closes out the current day and starts date.
+func (s *summarizer) roll(date string) *DailySummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	done := s.cur
+	s.past[done.Date] = done
+	for len(s.past) > summaryDays {
+		oldest := done.Date
+		for d := range s.past {
+			if d < oldest {
+				oldest = d
+			}
+		}
+		delete(s.past, oldest)
+	}
+	s.cur = newDailySummary(date)
+	return done
+}
+
+// get returns the summary for date, today's being still in progress.
+func (s *summarizer) get(date string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if date == "" || date == s.cur.Date {
+		return json.Marshal(s.cur)
+	}
+	if sum, ok := s.past[date]; ok {
+		return json.Marshal(sum)
+	}
+	if config.DataDir != "" {
+		return os.ReadFile(summaryPath(date))
+	}
+	return nil, os.ErrNotExist
+}
+
+func summaryPath(date string) string {
+	return filepath.Join(config.DataDir, "summary-"+date+".json")
+}
+
+// startSummary emits the daily summary at every local midnight.
+func (g *Gardener) startSummary() {
+	g.goSafe("summary", func() {
+		for {
+			t := now()
+			midnight := time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			select {
+			case <-g.ctx.Done():
+				return
+			case <-time.After(midnight.Sub(t)):
+			}
+			g.publishSummary(g.summary.roll(now().Format(time.DateOnly)))
+		}
+	})
+}
+
+// quantiles returns today's quantile estimates so far by sensor.
+func (s *summarizer) quantiles() map[string]map[string]float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	qs := make(map[string]map[string]float64, len(s.cur.Sensors))
+	for name, st := range s.cur.Sensors {
+		if len(st.Quantiles) > 0 {
+			qs[name] = maps.Clone(st.Quantiles)
+		}
+	}
+	return qs
+}
+
+// startQuantiles publishes today's quantiles every
+// config.QuantileInterval.
+func (g *Gardener) startQuantiles() {
+	if config.QuantileInterval <= 0 || len(config.Quantiles) == 0 {
+		return
+	}
+	g.every("quantiles", config.QuantileInterval, func(time.Time) {
+		jbuf, err := json.Marshal(g.summary.quantiles())
+		if err != nil {
+			slog.Error("quantiles marshal failed", "error", err)
+			return
+		}
+		g.publish("d/summary/quantiles", jbuf)
+	})
+}
+
+func (g *Gardener) publishSummary(sum *DailySummary) {
+	jbuf, err := json.Marshal(sum)
+	if err != nil {
+		slog.Error("summary marshal failed", "error", err)
+		return
+	}
+	slog.Info("daily summary", "date", sum.Date, "summary", string(jbuf))
+	g.publish("d/summary/daily", jbuf)
+
+	if config.DataDir != "" {
+		if err := os.WriteFile(summaryPath(sum.Date), jbuf, 0644); err != nil {
+			slog.Error("summary save failed", "date", sum.Date, "error", err)
+		}
+	}
+}
+
+// serveSummary handles /api/summary?date=YYYY-MM-DD, defaulting to
+// today so far.
+func (g *Gardener) serveSummary(w http.ResponseWriter, r *http.Request) {
+	date := r.URL.Query().Get("date")
+	if date != "" {
+		if _, err := time.Parse(time.DateOnly, date); err != nil {
+			http.Error(w, "date must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	jbuf, err := g.summary.get(date)
+	if errors.Is(err, os.ErrNotExist) {
+		http.Error(w, "no summary for "+date, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jbuf)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+// deviceTicker is a sensor's read ticker, kept so Stop can end it.
+type deviceTicker struct {
+	name   string
+	ticker *time.Ticker
+	stop   chan struct{}
+}
+
+// startTicker calls read every d until Stop, in place of a device's
+// own ticker, which cannot be stopped. A tick that races Stop is
+// dropped rather than read.
+func (g *Gardener) startTicker(name string, d time.Duration, read func(time.Time)) {
+	t := &deviceTicker{name: name, ticker: time.NewTicker(d), stop: make(chan struct{})}
+	g.mu.Lock()
+	g.tickers = append(g.tickers, t)
+	g.mu.Unlock()
+	g.goSafe(name, func() {
+		for {
+			select {
+			case <-t.stop:
+				return
+			case <-g.ctx.Done():
+				return
+			case tick := <-t.ticker.C:
+				if g.stopping.Load() {
+					return
+				}
+				read(tick)
+			}
+		}
+	})
+}
+
+// every calls fn every d in the background until the station stops.
+func (g *Gardener) every(name string, d time.Duration, fn func(time.Time)) {
+	g.goSafe(name, func() {
+		ticker := time.NewTicker(d)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-g.ctx.Done():
+				return
+			case t := <-ticker.C:
+				fn(t)
+			}
+		}
+	})
+}
+
+// stopTickers marks the station stopping and stops every sensor
+// ticker, so no reading is taken or published behind the shutdown.
+func (g *Gardener) stopTickers() {
+	if g.stopping.Swap(true) {
+		return
+	}
+	g.mu.Lock()
+	tickers := g.tickers
+	g.tickers = nil
+	g.mu.Unlock()
+	for _, t := range tickers {
+		t.ticker.Stop()
+		close(t.stop)
+	}
+	slog.Info("sensor tickers stopped", "tickers", len(tickers))
+}
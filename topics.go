@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// stateTopic returns the topic the named device publishes on: the one
+// -device-topic gives it or else def.
+func stateTopic(device, def string) string {
+	if t, ok := config.DeviceTopics[device]; ok {
+		return t
+	}
+	return def
+}
+
+// validateDeviceTopics checks that no two devices were given the same
+// topic, for state or commands.
+func validateDeviceTopics() error {
+	owner := make(map[string]string)
+	check := func(kind string, m stringMap) error {
+		names := make([]string, 0, len(m))
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			t := m[name]
+			if t == "" {
+				return fmt.Errorf("%s topic for %q is empty", kind, name)
+			}
+			who := kind + " topic of " + name
+			if prev, ok := owner[t]; ok {
+				return fmt.Errorf("topic %q is both the %s and the %s", t, prev, who)
+			}
+			owner[t] = who
+		}
+		return nil
+	}
+	if err := check("state", config.DeviceTopics); err != nil {
+		return err
+	}
+	return check("command", config.DeviceCommands)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ConfigIssue is one problem found with the resolved config.
+type ConfigIssue struct {
+	Option  string
+	Message string
+}
+
+func (i ConfigIssue) String() string {
+	if i.Option == "" {
+		return i.Message
+	}
+	return "-" + i.Option + ": " + i.Message
+}
+
+// ValidationResult is every problem found with the config: errors the
+// station refuses to start with, and warnings about settings that are
+// allowed but probably not meant.
+type ValidationResult struct {
+	Errors   []ConfigIssue
+	Warnings []ConfigIssue
+}
+
+func (r *ValidationResult) errorf(option, format string, args ...any) {
+	r.Errors = append(r.Errors, ConfigIssue{Option: option, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *ValidationResult) warnf(option, format string, args ...any) {
+	r.Warnings = append(r.Warnings, ConfigIssue{Option: option, Message: fmt.Sprintf(format, args...)})
+}
+
+// check records err, if any, as an error against option.
+func (r *ValidationResult) check(option string, err error) {
+	if err != nil {
+		r.errorf(option, "%v", err)
+	}
+}
+
+// OK reports whether the station can start.
+func (r ValidationResult) OK() bool {
+	return len(r.Errors) == 0
+}
+
+// Report lists the errors and then the warnings, one per line.
+func (r ValidationResult) Report() string {
+	var b strings.Builder
+	for _, i := range r.Errors {
+		fmt.Fprintf(&b, "error: %s\n", i)
+	}
+	for _, i := range r.Warnings {
+		fmt.Fprintf(&b, "warning: %s\n", i)
+	}
+	return b.String()
+}
+
+// minInterval is the shortest interval that is not suspicious.
+const minInterval = time.Second
+
+// validateConfig checks the whole resolved config, collecting every
+// issue rather than stopping at the first.
+func validateConfig() ValidationResult {
+	var r ValidationResult
+	r.check("gap-marker", validateGapMarker(config.GapMarker))
+	r.check("temp-unit", validateTempUnit(config.TempUnit))
+	r.check("display", validateDisplayType(config.DisplayType))
+	r.check("device-topic", validateDeviceTopics())
+	r.check("soil-mode", validateSoilModes())
+	r.check("calibrate", validateCalibrations())
+	profiles, err := loadProfiles(config.ProfilesFile)
+	if err != nil {
+		r.errorf("profiles", "%v", err)
+	} else if _, ok := profiles[config.Profile]; config.Profile != "" && !ok {
+		r.errorf("profile", "unknown profile %q", config.Profile)
+	}
+	r.check("offline-policy", validateOfflinePolicy(config.OfflinePolicy))
+	if config.OfflinePolicy != offlineNone && config.OfflineAfter <= 0 {
+		r.errorf("offline-after", "must be positive with an offline policy")
+	}
+	r.check("hysteresis", validateHysteresisAll(config.Hysteresis, profiles))
+	if _, err := time.LoadLocation(config.Timezone); err != nil {
+		r.errorf("timezone", "%v", err)
+	}
+	r.check("pin", validatePinMap())
+	if _, err := envSensors(); err != nil {
+		r.errorf("env-sensor", "%v", err)
+	}
+	if _, err := zoneSpecs(); err != nil {
+		r.errorf("zone-valve", "%v", err)
+	} else if len(config.ZoneValves) > 0 && !config.EnablePump {
+		r.errorf("zone-valve", "zones need the pump enabled")
+	}
+	if config.ZoneBusy != "queue" && config.ZoneBusy != "skip" {
+		r.errorf("zone-busy", "unknown %q: want queue or skip", config.ZoneBusy)
+	}
+	r.check("", validatePins(pinClaims()))
+	if config.MetricsPush != "" && config.MetricsPushInterval <= 0 {
+		r.errorf("metrics-push-interval", "must be positive to push metrics")
+	}
+	if _, err := parseTOD(config.DeepWaterAt); err != nil {
+		r.errorf("deep-water-at", "%v", err)
+	}
+
+	if config.LowThreshold >= config.HighThreshold {
+		r.warnf("low-threshold", "%g is not below -high-threshold %g, so watering stops as soon as it starts", config.LowThreshold, config.HighThreshold)
+	}
+	if config.AutoWater && !config.EnablePump {
+		r.warnf("auto-water", "automatic watering is on but the pump is disabled here, so c/pump must be handled elsewhere")
+	}
+	if config.PumpMaxRunSeconds <= 0 {
+		r.warnf("pump-max-run", "no limit on how long the pump may run")
+	}
+	if maxRun := time.Duration(config.PumpMaxRunSeconds) * time.Second; config.DeepWaterEvery > 0 && maxRun > 0 && config.DeepWaterDuration > maxRun {
+		r.warnf("deep-water-duration", "%s is over -pump-max-run %s, which cuts the deep soak short", config.DeepWaterDuration, maxRun)
+	}
+	if config.DeepWaterEvery > 0 && !config.AutoWater {
+		r.warnf("deep-water-every", "deep watering is scheduled but automatic watering is off, so it never runs")
+	}
+	for _, iv := range []struct {
+		option string
+		d      time.Duration
+	}{
+		{"state-interval", config.StateInterval},
+		{"soil-temp-interval", config.SoilTempInterval},
+		{"rules-interval", config.RulesInterval},
+		{"display-rotate", config.DisplayRotate},
+		{"metrics-push-interval", config.MetricsPushInterval},
+		{"quantile-interval", config.QuantileInterval},
+		{"state-get-interval", config.StateGetInterval},
+	} {
+		if iv.d > 0 && iv.d < minInterval {
+			r.warnf(iv.option, "%s is under %s and may load the station or the broker", iv.d, minInterval)
+		}
+	}
+	if config.PumpTestDuration > pumpTestMax {
+		r.warnf("pump-test-duration", "%s is over the %s cap, so tests run for %s", config.PumpTestDuration, pumpTestMax, pumpTestMax)
+	}
+	if config.MQTTKeepAlive > 0 && config.MQTTPingTimeout >= config.MQTTKeepAlive {
+		r.warnf("mqtt-ping-timeout", "%s is not below -mqtt-keepalive %s, so a lost link is noticed late", config.MQTTPingTimeout, config.MQTTKeepAlive)
+	}
+	if config.ShutdownTimeout <= 0 {
+		r.warnf("shutdown-timeout", "shutdown phases are given no time and will all time out")
+	}
+	return r
+}
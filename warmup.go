@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+// warmup discards a sensor's readings until it has been running for a
+// configured period. Some sensors, the BME280 in particular, return
+// garbage for the first few samples after power-up.
+type warmup struct {
+	name  string
+	until time.Time
+	done  bool
+}
+
+// newWarmup starts the warm-up period on now(), the clock the readings
+// are stamped with and checked against.
+func newWarmup(name string, d time.Duration) *warmup {
+	w := &warmup{name: name, until: now().Add(d), done: d <= 0}
+	if !w.done {
+		slog.Info("sensor warming up", "device", name, "duration", d)
+	}
+	return w
+}
+
+// ready reports whether a reading taken at t should be published and
+// acted on. It logs once when the warm-up period ends.
+func (w *warmup) ready(t time.Time) bool {
+	if w.done {
+		return true
+	}
+	if t.Before(w.until) {
+		slog.Debug("discarding warm-up reading", "device", w.name)
+		return false
+	}
+	w.done = true
+	slog.Info("sensor warm-up complete", "device", w.name)
+	return true
+}
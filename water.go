@@ -0,0 +1,635 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Pump control modes, published on d/pump/mode.
+const (
+	modeAuto      = "auto"
+	modeManualOn  = "manual-on"
+	modeManualOff = "manual-off"
+)
+
+// Hysteresis is the band above the low threshold at which watering
+// stops, either absolute, "5" for five points of moisture, or relative
+// to the threshold, "10%". Zero leaves the high threshold in charge.
+type Hysteresis struct {
+	Value   float64
+	Percent bool
+}
+
+func (h *Hysteresis) String() string {
+	if h == nil {
+		return "0"
+	}
+	s := strconv.FormatFloat(h.Value, 'f', -1, 64)
+	if h.Percent {
+		s += "%"
+	}
+	return s
+}
+
+func (h *Hysteresis) Set(v string) error {
+	num, pct := strings.CutSuffix(strings.TrimSpace(v), "%")
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return fmt.Errorf("bad hysteresis %q: want a number or a percentage", v)
+	}
+	if f < 0 {
+		return fmt.Errorf("hysteresis %q must not be negative", v)
+	}
+	h.Value, h.Percent = f, pct
+	return nil
+}
+
+// Band returns the hysteresis band for the low threshold.
+func (h Hysteresis) Band(low float64) float64 {
+	if h.Percent {
+		return low * h.Value / 100
+	}
+	return h.Value
+}
+
+// validateHysteresis checks the band fits between the thresholds.
+func validateHysteresis(h Hysteresis, low, high float64) error {
+	if h.Value == 0 {
+		return nil
+	}
+	if band := h.Band(low); band >= high-low {
+		return fmt.Errorf("hysteresis %s (%.2f) must be smaller than the %.2f between the low and high thresholds", h.String(), band, high-low)
+	}
+	return nil
+}
+
+// validateHysteresisAll checks the band against every pair of
+// thresholds that can be in effect: the configured ones, each
+// profile's and, on the normal profile, each range of the threshold
+// schedule, which stands in for the low threshold at its time of day.
+func validateHysteresisAll(h Hysteresis, profiles map[string]*Profile) error {
+	if h.Value == 0 {
+		return nil
+	}
+	type thresholds struct {
+		what      string
+		low, high float64
+	}
+	all := []thresholds{{"thresholds", config.LowThreshold, config.HighThreshold}}
+	for _, name := range slices.Sorted(maps.Keys(profiles)) {
+		p := profiles[name]
+		if p.Low != config.LowThreshold || p.High != config.HighThreshold {
+			all = append(all, thresholds{"profile " + name, p.Low, p.High})
+		}
+	}
+	high := config.HighThreshold
+	if p, ok := profiles[profileNormal]; ok {
+		high = p.High
+	}
+	for _, r := range config.ThresholdSchedule {
+		all = append(all, thresholds{fmt.Sprintf("threshold schedule %s-%s", fmtTOD(r.Start), fmtTOD(r.End)), r.Threshold, high})
+	}
+	var problems []string
+	for _, th := range all {
+		if err := validateHysteresis(h, th.low, th.high); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", th.what, err))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// WaterController decides when to water from the soil readings. It
+// turns the pump on when moisture falls below the low threshold for
+// the current time of day and off again once it reaches the high
+// threshold.
+//
+// The buttons take over from it: a manual on holds until a manual off,
+// and a manual off keeps automatic watering away for
+// config.ManualOverride before handing control back.
+type WaterController struct {
+	g *Gardener
+
+	mu          sync.Mutex
+	watering    bool
+	low         float64
+	high        float64
+	mode        string
+	manualUntil time.Time
+
+	// graceUntil is the end of the boot grace period during which
+	// automatic watering is suppressed.
+	graceUntil time.Time
+
+	// lastDeep is when the last deep soak started, and deepUntil the
+	// end of the cooldown after it during which top-ups are held off.
+	lastDeep  time.Time
+	deepUntil time.Time
+
+	// profile is the active watering profile. ranToday is how long it
+	// has watered on day, not counting the watering since startedAt,
+	// limit ends a watering at config.MaxWaterSeconds or the profile's
+	// limits at limitAt, and restUntil holds off top-ups after it does.
+	profile   *Profile
+	day       string
+	ranToday  time.Duration
+	startedAt time.Time
+	limit     *time.Timer
+	limitAt   time.Time
+	restUntil time.Time
+
+	// dry counts the readings in a row below the low threshold, for
+	// config.WaterConfirm.
+	dry int
+
+	// sim marks a forecast copy run forward by the simulation: it arms
+	// no timers and neither publishes nor logs.
+	sim bool
+}
+
+func newWaterController(g *Gardener) *WaterController {
+	return &WaterController{
+		g:    g,
+		low:  config.LowThreshold,
+		high: config.HighThreshold,
+		mode: modeAuto,
+	}
+}
+
+// startGrace starts the boot grace period on now(), so it must run
+// after the RTC has set the clock the readings are stamped with.
+func (w *WaterController) startGrace() {
+	if config.BootGrace <= 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.graceUntil = now().Add(config.BootGrace)
+	slog.Info("automatic watering suppressed after boot", "grace", config.BootGrace, "until", w.graceUntil)
+}
+
+// Suppressed reports whether automatic watering is held off by the boot
+// grace period, logging when the period ends.
+func (w *WaterController) Suppressed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.suppressed(now())
+}
+
+// suppressed is Suppressed at t with w.mu held.
+func (w *WaterController) suppressed(t time.Time) bool {
+	if w.graceUntil.IsZero() {
+		return false
+	}
+	if t.Before(w.graceUntil) {
+		return true
+	}
+	w.graceUntil = time.Time{}
+	w.log().Info("boot grace period over, automatic watering enabled")
+	return false
+}
+
+// Thresholds returns the low and high thresholds.
+func (w *WaterController) Thresholds() (low, high float64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.low, w.high
+}
+
+// SetThresholds changes the thresholds while the station runs.
+func (w *WaterController) SetThresholds(low, high float64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.low, w.high = low, high
+	slog.Info("watering thresholds changed", "low", low, "high", high)
+}
+
+// SetProfile swaps in a watering profile's thresholds and limits.
+func (w *WaterController) SetProfile(p *Profile) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.profile = p
+	w.low, w.high = p.Low, p.High
+	slog.Info("watering profile", "profile", p.Name, "low", p.Low, "high", p.High,
+		"max_run", p.MaxRun, "daily_cap", p.dailyCap())
+}
+
+// Profile returns the active watering profile.
+func (w *WaterController) Profile() *Profile {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.profile
+}
+
+// ran returns how long automatic watering has run on t's day. w.mu
+// must be held.
+func (w *WaterController) ran(t time.Time) time.Duration {
+	if day := t.Format(time.DateOnly); day != w.day {
+		w.day, w.ranToday = day, 0
+	}
+	d := w.ranToday
+	if w.watering {
+		d += t.Sub(w.startedAt)
+	}
+	return d
+}
+
+// started begins accounting a watering started at t and arms the
+// profile's limits. It reports false, and the watering must not start,
+// when the day's cap is used up. w.mu must be held.
+func (w *WaterController) started(t time.Time) bool {
+	limit := time.Duration(config.MaxWaterSeconds) * time.Second
+	if w.profile != nil {
+		if m := w.profile.MaxRun; m > 0 && (limit == 0 || m < limit) {
+			limit = m
+		}
+		if c := w.profile.dailyCap(); c > 0 {
+			left := c - w.ran(t)
+			if left <= 0 {
+				w.log().Warn("daily watering cap reached, watering skipped", "profile", w.profile.Name, "cap", c)
+				return false
+			}
+			if limit == 0 || left < limit {
+				limit = left
+			}
+		}
+	}
+	w.ran(t)
+	w.startedAt = t
+	if limit > 0 {
+		w.limitAt = t.Add(limit)
+		if !w.sim {
+			w.limit = time.AfterFunc(limit, w.limitReached)
+		}
+	}
+	return true
+}
+
+// stopped ends the accounting of the watering under way at t. w.mu
+// must be held.
+func (w *WaterController) stopped(t time.Time) {
+	w.ranToday = w.ran(t)
+	w.limitAt = time.Time{}
+	if w.limit != nil {
+		w.limit.Stop()
+		w.limit = nil
+	}
+}
+
+// endWatering ends any automatic watering under way at t. w.mu must be
+// held.
+func (w *WaterController) endWatering(t time.Time) {
+	if w.watering {
+		w.stopped(t)
+	}
+	w.watering = false
+}
+
+// limitRest holds off the next top-up after a watering cut short by
+// a limit, so the water can soak in before the soil is judged again.
+const limitRest = 30 * time.Minute
+
+// cutShort ends the watering under way at t on a limit and rests
+// before the next top-up. w.mu must be held.
+func (w *WaterController) cutShort(t time.Time) {
+	w.endWatering(t)
+	w.restUntil = t.Add(limitRest)
+}
+
+// pumpCutOff is told the pump was forced off at its maximum runtime,
+// and rests as after any other limit rather than waiting for a
+// watering that is no longer running.
+func (w *WaterController) pumpCutOff() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.watering {
+		return
+	}
+	w.cutShort(now())
+	slog.Info("stop watering", "type", wateringTopUp, "reason", "pump max runtime", "rest_until", w.restUntil)
+}
+
+// emergencyStopped is told the pump was switched off by an emergency
+// stop, and ends the watering under way, so that after the reset the
+// soil is judged afresh rather than the watering counted as still on.
+func (w *WaterController) emergencyStopped() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.watering {
+		return
+	}
+	w.endWatering(now())
+	slog.Info("stop watering", "type", wateringTopUp, "reason", "emergency stop")
+}
+
+// limitReached stops a watering that hit its limits.
+func (w *WaterController) limitReached() {
+	w.mu.Lock()
+	if !w.watering {
+		w.mu.Unlock()
+		return
+	}
+	w.cutShort(now())
+	slog.Warn("watering limit reached, stop watering", "profile", w.profile.Name, "ran_today", w.ranToday, "rest_until", w.restUntil)
+	w.mu.Unlock()
+	w.pumpCommand(false)
+}
+
+// Mode returns the current control mode.
+func (w *WaterController) Mode() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.mode
+}
+
+// setMode changes the control mode and publishes it. w.mu must be held.
+func (w *WaterController) setMode(mode string) {
+	if mode == w.mode {
+		return
+	}
+	w.log().Info("pump control mode", "from", w.mode, "to", mode)
+	w.mode = mode
+	if !w.sim {
+		w.g.publish("d/pump/mode", []byte(mode))
+	}
+}
+
+// override returns the mode and when a manual off expires, for saving.
+func (w *WaterController) override() (string, time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.mode, w.manualUntil
+}
+
+// restoreOverride restores a saved manual off that has not yet expired.
+// A manual on is not restored because the pump is off after a restart.
+func (w *WaterController) restoreOverride(mode string, until time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if mode == modeManualOff && now().Before(until) {
+		w.manualUntil = until
+		w.setMode(modeManualOff)
+	}
+}
+
+// dailyRun returns the day the daily run is counted on, how long
+// automatic watering has run on it so far and the end of any rest after
+// a watering limit, for saving across a restart.
+func (w *WaterController) dailyRun() (string, time.Duration, time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ran := w.ran(now())
+	return w.day, ran, w.restUntil
+}
+
+// restoreDailyRun restores the saved daily run if it is for today, so
+// a restart does not reset the daily cap, and a rest that has not yet
+// ended.
+func (w *WaterController) restoreDailyRun(day string, ran time.Duration, restUntil time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	t := now()
+	if day == t.Format(time.DateOnly) {
+		w.day, w.ranToday = day, ran
+	}
+	if t.Before(restUntil) {
+		w.restUntil = restUntil
+	}
+}
+
+// Manual hands the pump to the buttons, turning it on or off.
+func (w *WaterController) Manual(on bool) {
+	w.mu.Lock()
+	w.endWatering(now())
+	if on {
+		w.setMode(modeManualOn)
+	} else {
+		w.manualUntil = now().Add(config.ManualOverride)
+		w.setMode(modeManualOff)
+	}
+	w.mu.Unlock()
+
+	if w.g.pump == nil {
+		return
+	}
+	var err error
+	if on {
+		err = w.g.pump.On()
+	} else {
+		err = w.g.pump.Off()
+	}
+	if err != nil {
+		slog.Error("manual pump control failed", "on", on, "error", err)
+	}
+}
+
+// stopAt returns the moisture at which watering stops: the low
+// threshold plus the hysteresis band if one is set, never beyond the
+// high threshold.
+func stopAt(low, high float64) float64 {
+	if config.Hysteresis.Value <= 0 {
+		return high
+	}
+	return min(low+config.Hysteresis.Band(low), high)
+}
+
+// decide is the automatic watering decision, kept apart from the
+// hardware and the controller's state. Given whether
+// watering is under way and a soil reading, it returns whether watering
+// should be under way and the threshold that decided it.
+func decide(watering bool, value, low, high float64) (bool, float64) {
+	switch {
+	case !watering && value < low:
+		return true, low
+	case watering && value >= stopAt(low, high):
+		return false, stopAt(low, high)
+	}
+	return watering, 0
+}
+
+// Update feeds a soil reading taken at t into the controller.
+func (w *WaterController) Update(value float64, t time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if changed, _ := w.update(value, t); changed {
+		w.pumpCommand(w.watering)
+	}
+}
+
+// update is Update short of the pump command, which it leaves to the
+// caller: it reports whether watering started or stopped and the
+// threshold that decided it. The simulation runs it on a forecast copy.
+// w.mu must be held.
+func (w *WaterController) update(value float64, t time.Time) (bool, float64) {
+	low, held := w.heldOff(value, t)
+	if held {
+		// Dry readings only confirm a watering when they come in a
+		// row while it could start.
+		w.dry = 0
+		return false, 0
+	}
+	watering, threshold := decide(w.watering, value, low, w.high)
+	if watering && !w.watering {
+		// One noisy reading below the threshold must not start a
+		// watering; config.WaterConfirm in a row do.
+		if w.dry++; w.dry < config.WaterConfirm {
+			w.log().Debug("soil below threshold, awaiting confirmation", "value", value, "threshold", threshold,
+				"readings", w.dry, "confirm", config.WaterConfirm)
+			return false, 0
+		}
+	}
+	w.dry = 0
+	if watering == w.watering {
+		return false, 0
+	}
+	if watering && !w.started(t) {
+		return false, 0
+	}
+	if !watering {
+		w.stopped(t)
+	}
+	w.watering = watering
+	if watering {
+		w.log().Info("soil dry, start watering", "type", wateringTopUp, "value", value, "threshold", threshold)
+	} else {
+		w.log().Info("soil wet, stop watering", "type", wateringTopUp, "value", value, "threshold", threshold)
+	}
+	return true, threshold
+}
+
+// heldOff reports whether automatic watering is held off at t: disabled,
+// suppressed, stopped, in manual, or for a watering not yet under way,
+// by recent rain, a deep soak cooldown or a rest after a limit.
+// Otherwise it returns the low threshold in effect. w.mu must be held.
+func (w *WaterController) heldOff(value float64, t time.Time) (float64, bool) {
+	if !config.AutoWater {
+		return 0, true
+	}
+	if w.suppressed(t) || w.g.emergencyStopped() || w.g.offlinePolicy() == offlineConservative {
+		return 0, true
+	}
+	switch w.mode {
+	case modeManualOn:
+		return 0, true
+	case modeManualOff:
+		if t.Before(w.manualUntil) {
+			return 0, true
+		}
+		w.setMode(modeAuto)
+	}
+
+	low := w.low
+	if w.profile == nil || w.profile.Name == profileNormal {
+		low = config.ThresholdSchedule.At(t, w.low)
+	}
+	low, rained := w.g.weather.adjust(low, w.high, t)
+	if rained && !w.watering {
+		w.log().Debug("recent rain, watering skipped", "value", value, "threshold", low)
+		return 0, true
+	}
+	if !w.watering && w.deepCooldown(t) {
+		w.log().Debug("deep watering cooldown, top-up skipped", "value", value, "threshold", low, "until", w.deepUntil)
+		return 0, true
+	}
+	if !w.watering && t.Before(w.restUntil) {
+		w.log().Debug("resting after a watering limit, top-up skipped", "value", value, "threshold", low, "until", w.restUntil)
+		return 0, true
+	}
+	return low, false
+}
+
+// log returns the logger for the controller's decisions, which
+// discards them on a forecast copy.
+func (w *WaterController) log() *slog.Logger {
+	if w.sim {
+		return discardLogger
+	}
+	return slog.Default()
+}
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// forecast returns a copy of the controller as it stands, for the
+// simulation to run forward without touching the station.
+func (w *WaterController) forecast() *WaterController {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return &WaterController{
+		g:           w.g,
+		watering:    w.watering,
+		low:         w.low,
+		high:        w.high,
+		mode:        w.mode,
+		manualUntil: w.manualUntil,
+		graceUntil:  w.graceUntil,
+		lastDeep:    w.lastDeep,
+		deepUntil:   w.deepUntil,
+		profile:     w.profile,
+		day:         w.day,
+		ranToday:    w.ranToday,
+		startedAt:   w.startedAt,
+		limitAt:     w.limitAt,
+		restUntil:   w.restUntil,
+		dry:         w.dry,
+		sim:         true,
+	}
+}
+
+// pumpCommand turns the pump on or off: through c/pump on the broker,
+// or, while offline under the local-autonomous policy, directly.
+func (w *WaterController) pumpCommand(on bool) {
+	cmd := "off"
+	if on {
+		cmd = "on"
+	}
+	if w.g.offlinePolicy() != offlineLocal {
+		w.g.publishConfirmed("c/pump", []byte(cmd), nil)
+		return
+	}
+	if w.g.pump == nil {
+		slog.Warn("offline with no local pump, watering command lost", "command", cmd)
+		return
+	}
+	var err error
+	if on {
+		err = w.g.pump.On()
+	} else {
+		err = w.g.pump.Off()
+	}
+	if err != nil {
+		slog.Error("local pump control failed", "command", cmd, "error", err)
+	}
+}
+
+// offline applies the offline policy as the broker goes offline.
+func (w *WaterController) offline(policy string) {
+	switch policy {
+	case offlineLocal:
+		slog.Info("watering mode", "mode", "local", "reason", "broker offline")
+	case offlineConservative:
+		w.mu.Lock()
+		watering := w.watering
+		w.endWatering(now())
+		w.mu.Unlock()
+		slog.Info("watering mode", "mode", "disabled", "reason", "broker offline")
+		if w.g.pump != nil && watering {
+			if err := w.g.pump.Off(); err != nil {
+				slog.Error("offline pump off failed", "error", err)
+			}
+		}
+	}
+}
+
+// online hands watering back to the broker once it is reachable.
+func (w *WaterController) online() {
+	slog.Info("watering mode", "mode", "auto", "reason", "broker online")
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testController returns a controller whose pump commands are held in
+// an unsent publish queue, for pumpCommands to read back.
+func testController(t *testing.T) *WaterController {
+	t.Helper()
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config.AutoWater = true
+	config.LowThreshold = 30
+	config.HighThreshold = 50
+	config.WaterConfirm = 3
+	config.BootGrace = 0
+	config.DeliveryTimeout = 0
+	config.MaxWaterSeconds = 0
+
+	g := &Gardener{}
+	g.pubq = &publishQueue{g: g, size: 100, wake: make(chan struct{}, 1)}
+	g.water = newWaterController(g)
+	return g.water
+}
+
+// pumpCommands returns the commands published on c/pump so far.
+func pumpCommands(w *WaterController) []string {
+	w.g.pubq.mu.Lock()
+	defer w.g.pubq.mu.Unlock()
+	var cmds []string
+	for _, m := range w.g.pubq.priority {
+		if m.topic == "c/pump" {
+			cmds = append(cmds, string(m.data))
+		}
+	}
+	return cmds
+}
+
+func TestWaterControllerSequence(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		soil  []float64
+		wants []string
+	}{
+		{"one dip ignored", []float64{40, 25, 40, 35, 40}, nil},
+		{"dips not in a row", []float64{25, 25, 40, 25, 25, 40}, nil},
+		{"confirmed dry waters", []float64{40, 25, 26, 24}, []string{"on"}},
+		{"waters until wet", []float64{25, 25, 25, 35, 45, 55}, []string{"on", "off"}},
+		{"waters again once dry", []float64{25, 25, 25, 55, 40, 20, 20, 20}, []string{"on", "off", "on"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := testController(t)
+			at := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+			for _, v := range tc.soil {
+				w.Update(v, at)
+				at = at.Add(time.Minute)
+			}
+			if got := pumpCommands(w); !slices.Equal(got, tc.wants) {
+				t.Errorf("soil %v: pump commands %q, want %q", tc.soil, got, tc.wants)
+			}
+		})
+	}
+}
+
+// TestDryCountResetWhileHeld checks dry readings taken while watering
+// is held off do not count towards config.WaterConfirm.
+func TestDryCountResetWhileHeld(t *testing.T) {
+	w := testController(t)
+	at := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	w.Update(25, at)
+	w.Update(25, at.Add(time.Minute))
+	w.mu.Lock()
+	w.restUntil = at.Add(3 * time.Minute)
+	w.mu.Unlock()
+	w.Update(25, at.Add(2*time.Minute)) // resting
+
+	w.Update(25, at.Add(3*time.Minute))
+	w.Update(25, at.Add(4*time.Minute))
+	if got := pumpCommands(w); len(got) > 0 {
+		t.Fatalf("watered on %q, counting dry readings from before the rest", got)
+	}
+	w.Update(25, at.Add(5*time.Minute))
+	if got := pumpCommands(w); !slices.Equal(got, []string{"on"}) {
+		t.Errorf("pump commands %q after three dry readings in a row, want [on]", got)
+	}
+}
+
+// TestMaxWaterSeconds checks a watering arms its limit at the cap and,
+// when it fires, stops with the soil still dry and rests before the
+// next.
+func TestMaxWaterSeconds(t *testing.T) {
+	w := testController(t)
+	config.WaterConfirm = 1
+	config.MaxWaterSeconds = 90
+	w.SetProfile(defaultProfiles()[profileNormal])
+
+	start := now()
+	w.Update(20, start)
+	w.mu.Lock()
+	limitAt, timer := w.limitAt, w.limit
+	w.mu.Unlock()
+	if want := start.Add(90 * time.Second); !limitAt.Equal(want) {
+		t.Errorf("limit at %s, want %s", limitAt, want)
+	}
+	// Fire the limit now rather than wait out the cap.
+	if timer == nil || !timer.Stop() {
+		t.Fatal("no limit armed")
+	}
+	w.limitReached()
+
+	w.Update(20, now())
+	if got := pumpCommands(w); !slices.Equal(got, []string{"on", "off"}) {
+		t.Fatalf("pump commands %q, want [on off] with the soil still dry", got)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.watering || !now().Before(w.restUntil) {
+		t.Errorf("watering %t, resting until %s, want stopped and resting", w.watering, w.restUntil)
+	}
+}
+
+func TestBootGraceOnStationClock(t *testing.T) {
+	w := testController(t)
+	config.BootGrace = 10 * time.Minute
+	config.WaterConfirm = 1
+	saved := clockOffset
+	t.Cleanup(func() { clockOffset = saved })
+	clockOffset = time.Hour // the RTC is an hour ahead of the system clock
+
+	w.startGrace()
+	start := now()
+	w.Update(20, start.Add(time.Minute))
+	if got := pumpCommands(w); len(got) > 0 {
+		t.Fatalf("watered during the boot grace period: %q", got)
+	}
+	w.Update(20, start.Add(11*time.Minute))
+	if got := pumpCommands(w); !slices.Equal(got, []string{"on"}) {
+		t.Errorf("pump commands after the grace period %q, want [on]", got)
+	}
+}
+
+func TestHysteresisSet(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Hysteresis
+		err  bool
+	}{
+		{"5", Hysteresis{Value: 5}, false},
+		{" 2.5 ", Hysteresis{Value: 2.5}, false},
+		{"10%", Hysteresis{Value: 10, Percent: true}, false},
+		{"0", Hysteresis{}, false},
+		{"-1", Hysteresis{}, true},
+		{"-5%", Hysteresis{}, true},
+		{"five", Hysteresis{}, true},
+		{"%", Hysteresis{}, true},
+	} {
+		var h Hysteresis
+		err := h.Set(tc.in)
+		if (err != nil) != tc.err {
+			t.Errorf("Set(%q): error %v, want error %v", tc.in, err, tc.err)
+			continue
+		}
+		if err == nil && h != tc.want {
+			t.Errorf("Set(%q) = %+v, want %+v", tc.in, h, tc.want)
+		}
+	}
+}
+
+func TestHysteresisStop(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	for _, tc := range []struct {
+		name      string
+		h         Hysteresis
+		low, high float64
+		stop      float64
+	}{
+		{"none", Hysteresis{}, 30, 50, 50},
+		{"absolute", Hysteresis{Value: 5}, 30, 50, 35},
+		{"percent", Hysteresis{Value: 10, Percent: true}, 30, 50, 33},
+		{"capped at high", Hysteresis{Value: 30}, 30, 50, 50},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config.Hysteresis = tc.h
+			if got := stopAt(tc.low, tc.high); got != tc.stop {
+				t.Fatalf("stopAt(%g, %g) = %g, want %g", tc.low, tc.high, got, tc.stop)
+			}
+			if watering, _ := decide(true, tc.stop-0.01, tc.low, tc.high); !watering {
+				t.Errorf("stopped just below %g", tc.stop)
+			}
+			if watering, threshold := decide(true, tc.stop, tc.low, tc.high); watering || threshold != tc.stop {
+				t.Errorf("at %g: watering %v, threshold %g; want stopped at %g", tc.stop, watering, threshold, tc.stop)
+			}
+			if watering, _ := decide(false, tc.stop-0.01, tc.low, tc.high); watering {
+				t.Errorf("started above the low threshold at %g", tc.stop-0.01)
+			}
+		})
+	}
+}
+
+func TestValidateHysteresis(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	night := ThresholdSchedule{{Start: 22 * time.Hour, End: 5 * time.Hour, Threshold: 45}}
+	for _, tc := range []struct {
+		name     string
+		h        Hysteresis
+		schedule ThresholdSchedule
+		profiles map[string]*Profile
+		want     string // in the error; empty for none
+	}{
+		{"none", Hysteresis{}, night, nil, ""},
+		{"absolute fits", Hysteresis{Value: 19.9}, nil, nil, ""},
+		{"absolute fills the gap", Hysteresis{Value: 20}, nil, nil, "thresholds: hysteresis 20 (20.00) must be smaller than the 20.00"},
+		{"percent fits", Hysteresis{Value: 66, Percent: true}, nil, nil, ""},
+		{"percent fills the gap", Hysteresis{Value: 200.0 / 3, Percent: true}, nil, nil, "thresholds:"},
+		{"too wide for the schedule", Hysteresis{Value: 5}, night, nil, "threshold schedule 22:00-05:00: hysteresis 5 (5.00) must be smaller than the 5.00"},
+		{"too wide for a profile", Hysteresis{Value: 8}, nil,
+			map[string]*Profile{"dry": {Name: "dry", Low: 40, High: 45}}, "profile dry:"},
+		{"schedule under the normal profile's high", Hysteresis{Value: 10}, night,
+			map[string]*Profile{profileNormal: {Name: profileNormal, Low: 30, High: 60}}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config.LowThreshold, config.HighThreshold = 30, 50
+			config.ThresholdSchedule = tc.schedule
+			err := validateHysteresisAll(tc.h, tc.profiles)
+			switch {
+			case tc.want == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+				t.Errorf("error %v, want one containing %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rustyeddy/otto/messenger"
+)
+
+// weatherFeed holds the latest reference evapotranspiration (ET0, in
+// mm a day) and rainfall (mm) published by an external weather feed on
+// config.ET0Topic and config.RainTopic. Each is used only while younger
+// than config.WeatherMaxAge, so a feed that stops degrades to plain
+// soil thresholds.
+type weatherFeed struct {
+	mu     sync.Mutex
+	et0    float64
+	et0At  time.Time
+	rain   float64
+	rainAt time.Time
+}
+
+func parseWeather(msg *messenger.Msg) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(string(msg.Data)), 64)
+	if err == nil && !finite(v) {
+		err = errors.New("not a number")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("bad weather value %q on %s: %w", msg.Data, msg.Topic, err)
+	}
+	return v, nil
+}
+
+func (w *weatherFeed) handleET0(msg *messenger.Msg) error {
+	v, err := parseWeather(msg)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.et0, w.et0At = v, now()
+	slog.Info("et0 updated", "et0", v)
+	return nil
+}
+
+func (w *weatherFeed) handleRain(msg *messenger.Msg) error {
+	v, err := parseWeather(msg)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rain, w.rainAt = v, now()
+	slog.Info("rainfall updated", "rain", v)
+	return nil
+}
+
+func fresh(at, t time.Time) bool {
+	return !at.IsZero() && t.Sub(at) <= config.WeatherMaxAge
+}
+
+// lastRain returns the last rainfall reported and when.
+func (w *weatherFeed) lastRain() (float64, time.Time) {
+	if w == nil {
+		return 0, time.Time{}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rain, w.rainAt
+}
+
+// restoreRain restores a saved rainfall report, so a restart inside
+// the rain delay does not water. A stale report is dropped.
+func (w *weatherFeed) restoreRain(rain float64, at time.Time) {
+	if w == nil || !fresh(at, now()) {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rain, w.rainAt = rain, at
+}
+
+// adjust combines the weather with the low threshold at t. Recent rain
+// of config.RainSkip mm or more skips starting a watering. ET0 moves
+// the threshold by config.ET0Gain points for every mm a day it is above
+// or below config.ET0Ref, so a hot, dry day waters earlier and a dull
+// one later, never below zero or up to high.
+func (w *weatherFeed) adjust(low, high float64, t time.Time) (float64, bool) {
+	if w == nil {
+		return low, false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	skip := config.RainSkip > 0 && fresh(w.rainAt, t) && w.rain >= config.RainSkip
+	if fresh(w.et0At, t) {
+		low = min(max(low+config.ET0Gain*(w.et0-config.ET0Ref), 0), high-1)
+	}
+	return low, skip
+}
+
+func (g *Gardener) initWeather() {
+	if config.ET0Topic == "" && config.RainTopic == "" {
+		return
+	}
+	g.weather = &weatherFeed{}
+}
+
+// subscribeWeather subscribes to the weather feed topics.
+func (g *Gardener) subscribeWeather() {
+	if g.weather == nil {
+		return
+	}
+	if config.ET0Topic != "" {
+		g.subscribe(config.ET0Topic, g.weather.handleET0)
+	}
+	if config.RainTopic != "" {
+		g.subscribe(config.RainTopic, g.weather.handleRain)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rustyeddy/devices/relay"
+	"github.com/rustyeddy/otto/messenger"
+)
+
+// zoneActiveTopic carries the zone holding the shared pump, or "none".
+const zoneActiveTopic = "d/pump/zone"
+
+// errZoneBusy rejects a zone that wants water while another holds the
+// pump and config.ZoneBusy is "skip".
+var errZoneBusy = errors.New("shared pump busy")
+
+// zoneSpec is a zone watered through its own valve off the shared pump.
+type zoneSpec struct {
+	name string
+	pin  int
+}
+
+// zoneSpecs parses config.ZoneValves.
+func zoneSpecs() ([]zoneSpec, error) {
+	var specs []zoneSpec
+	for _, s := range config.ZoneValves {
+		name, pin, ok := strings.Cut(s, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("zone valve %q: expected name=pin", s)
+		}
+		p, err := strconv.Atoi(pin)
+		if err != nil || p < 0 {
+			return nil, fmt.Errorf("zone valve %q: bad pin %q", s, pin)
+		}
+		specs = append(specs, zoneSpec{name: name, pin: p})
+	}
+	return specs, nil
+}
+
+// zoneValveName is the device name of a zone's valve relay.
+func zoneValveName(zone string) string {
+	return "valve-" + zone
+}
+
+// Zones shares one pump between zones that each have a valve. Only one
+// zone holds the pump at a time, with its valve open and every other
+// closed. A zone that wants water while the pump is held waits its turn
+// or, with config.ZoneBusy "skip", is turned away.
+type Zones struct {
+	g *Gardener
+
+	mu     sync.Mutex
+	valves map[string]*relay.Relay
+	active string
+	queue  []string
+}
+
+// Request asks for water for zone.
+func (z *Zones) Request(zone string) error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	switch {
+	case z.active == zone || slices.Contains(z.queue, zone):
+		return nil
+	case z.active == "":
+		return z.start(zone)
+	case config.ZoneBusy == "skip":
+		slog.Info("zone skipped, shared pump busy", "zone", zone, "active", z.active)
+		return errZoneBusy
+	}
+	z.queue = append(z.queue, zone)
+	slog.Info("zone queued for shared pump", "zone", zone, "active", z.active, "position", len(z.queue))
+	return nil
+}
+
+// Release is zone saying it has had enough water. The pump goes to the
+// next zone waiting, if any, without being switched off in between.
+func (z *Zones) Release(zone string) error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if i := slices.Index(z.queue, zone); i >= 0 {
+		z.queue = slices.Delete(z.queue, i, i+1)
+		slog.Info("zone left shared pump queue", "zone", zone)
+		return nil
+	}
+	if z.active != zone {
+		return nil
+	}
+
+	if len(z.queue) > 0 {
+		next := z.queue[0]
+		z.queue = z.queue[1:]
+		if err := z.valves[next].On(); err != nil {
+			z.queue = append([]string{next}, z.queue...)
+			return z.stop()
+		}
+		err := z.valves[zone].Off()
+		slog.Info("shared pump handed over", "from", zone, "to", next)
+		z.setActive(next)
+		return err
+	}
+	return z.stop()
+}
+
+// start opens zone's valve and then starts the pump. z.mu must be held.
+func (z *Zones) start(zone string) error {
+	v := z.valves[zone]
+	if err := v.On(); err != nil {
+		return err
+	}
+	if err := z.g.pump.On(); err != nil {
+		if verr := v.Off(); verr != nil {
+			slog.Error("zone valve close failed", "zone", zone, "error", verr)
+		}
+		return err
+	}
+	z.setActive(zone)
+	return nil
+}
+
+// stop turns the pump off and then closes the active zone's valve. The
+// pump goes off at once, minimum runtime or not, so that it never runs
+// against the closed valve. z.mu must be held.
+func (z *Zones) stop() error {
+	zone := z.active
+	err := z.g.pump.ForceOff()
+	if verr := z.valves[zone].Off(); err == nil {
+		err = verr
+	}
+	z.setActive("")
+	return err
+}
+
+// setActive records and publishes the zone holding the pump. z.mu must
+// be held.
+func (z *Zones) setActive(zone string) {
+	z.active = zone
+	if zone == "" {
+		zone = "none"
+	}
+	slog.Info("shared pump zone", "zone", zone)
+	z.g.publish(zoneActiveTopic, []byte(zone))
+}
+
+// pumpCutOff is told the pump was forced off at its maximum runtime.
+// The active zone's valve is closed and the queue released, so that
+// the zones can ask for water again rather than wait on a pump that
+// is no longer running for anyone.
+func (z *Zones) pumpCutOff() {
+	if z == nil {
+		return
+	}
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if z.active == "" {
+		return
+	}
+	if err := z.valves[z.active].Off(); err != nil {
+		slog.Error("zone valve close failed", "zone", z.active, "error", err)
+	}
+	slog.Warn("zone cut off at pump max runtime", "zone", z.active, "released", z.queue)
+	z.queue = nil
+	z.setActive("")
+}
+
+// Off closes every valve and empties the queue. It is the zones'
+// shutdown and emergency stop, after the pump itself is off.
+func (z *Zones) Off() error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	var errs []error
+	for name, v := range z.valves {
+		if err := v.Off(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	z.queue = nil
+	if z.active != "" {
+		z.setActive("")
+	}
+	return errors.Join(errs...)
+}
+
+// handler handles c/zone/<zone>, "on" to ask for water and "off" to
+// release the pump.
+func (z *Zones) handler(zone string) messenger.MsgHandler {
+	return func(msg *messenger.Msg) error {
+		switch strings.TrimSpace(string(msg.Data)) {
+		case "on":
+			return z.Request(zone)
+		case "off":
+			return z.Release(zone)
+		default:
+			return fmt.Errorf("unknown zone command: %q", msg.Data)
+		}
+	}
+}
+
+func (g *Gardener) initZones() {
+	specs, err := zoneSpecs()
+	if err != nil {
+		panic(err)
+	}
+	if len(specs) == 0 {
+		return
+	}
+	if g.pump == nil {
+		panic("zone valves need the pump enabled")
+	}
+	if config.ZoneBusy != "queue" && config.ZoneBusy != "skip" {
+		panic(fmt.Sprintf("unknown -zone-busy %q: want queue or skip", config.ZoneBusy))
+	}
+
+	z := &Zones{g: g, valves: make(map[string]*relay.Relay)}
+	for _, s := range specs {
+		v, err := relay.New(zoneValveName(s.name), s.pin)
+		if err != nil {
+			panic(err)
+		}
+		g.addDevice(v)
+		z.valves[s.name] = v
+		g.subscribe("c/zone/"+s.name, z.handler(s.name))
+	}
+	g.addActuator("zones", z.Off)
+	g.zones = z
+}